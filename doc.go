@@ -0,0 +1,39 @@
+package main
+
+import (
+	"JureBevc/peepoo/parser"
+	"JureBevc/peepoo/util"
+	"fmt"
+	"strings"
+)
+
+// Prints the signature and doc comment of every function in the program
+func printDocs(node *util.TreeNode[parser.ParseNode]) {
+	if node.Value.Name == "FUNC" {
+		fmt.Println(strings.Join(declarationWords(node), " "))
+		for _, line := range strings.Split(node.Children[0].Value.Token.Doc, "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+		fmt.Println()
+	}
+
+	for _, child := range node.Children {
+		printDocs(child)
+	}
+}
+
+// Source words of a function declaration, without its body
+func declarationWords(node *util.TreeNode[parser.ParseNode]) []string {
+	if node.Value.Name == "FUNCBODY" {
+		return nil
+	}
+	if node.Value.IsTerminal {
+		return []string{node.Value.Value}
+	}
+
+	words := []string{}
+	for _, child := range node.Children {
+		words = append(words, declarationWords(child)...)
+	}
+	return words
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"JureBevc/peepoo/parser"
+	"JureBevc/peepoo/util"
+	"testing"
+)
+
+// Collects the doc comments of the functions in a program by function name
+func functionDocs(node *util.TreeNode[parser.ParseNode], docs map[string]string) {
+	if node.Value.Name == "FUNC" {
+		docs[node.Children[1].Value.Value] = node.Children[0].Value.Token.Doc
+	}
+	for _, child := range node.Children {
+		functionDocs(child, docs)
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	ptree, err := parseProgram("examples/comments.peepoo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docs := map[string]string{}
+	functionDocs(ptree, docs)
+	expected := map[string]string{
+		"PAPOPE": "Doubles a value.\nReturns the doubled value.",
+		"PAPOPI": "",
+	}
+	for name, doc := range expected {
+		if docs[name] != doc {
+			t.Errorf("doc of %s is %q, expected %q", name, docs[name], doc)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"JureBevc/peepoo/runtime"
+	"JureBevc/peepoo/util"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Golden runs use a fixed seed so random numbers are the same every time
+const goldenSeed = 1
+
+// Runs a program with stdin read from its .in file and arguments from the lines of its .args file,
+// and compares the output with its .out file.
+// A program that does not exit with 0 has its exit code written after its output.
+// With update set, the .out file is written instead.
+func runGolden(programFile string, update bool) error {
+	basePath := strings.TrimSuffix(programFile, ".peepoo")
+
+	var output bytes.Buffer
+	ptree, err := parseProgram(programFile)
+	if err != nil {
+		fmt.Fprintln(&output, err)
+		fmt.Fprintf(&output, "exit %d\n", util.ExitSyntaxError)
+	} else {
+		var stdin io.Reader = strings.NewReader("")
+		if inputFile, err := os.Open(basePath + ".in"); err == nil {
+			defer inputFile.Close()
+			stdin = inputFile
+		}
+
+		programArgs := []string{}
+		if argsData, err := os.ReadFile(basePath + ".args"); err == nil {
+			programArgs = strings.Split(strings.TrimSuffix(string(argsData), "\n"), "\n")
+		}
+
+		runtime.Stdout = &output
+		runtime.SetStdin(stdin)
+		runtime.SetSeed(goldenSeed)
+		runtime.ProgramArgs = programArgs
+		if code := runtime.RunTree(ptree); code != 0 {
+			fmt.Fprintf(&output, "exit %d\n", code)
+		}
+		runtime.Stdout = os.Stdout
+		runtime.SetStdin(os.Stdin)
+		runtime.ProgramArgs = nil
+	}
+
+	if update {
+		return os.WriteFile(basePath+".out", output.Bytes(), 0644)
+	}
+
+	expected, err := os.ReadFile(basePath + ".out")
+	if err != nil {
+		return fmt.Errorf("failed to read expected output: %w", err)
+	}
+	if !bytes.Equal(output.Bytes(), expected) {
+		return fmt.Errorf("output does not match %s.out\n--- got\n%s--- expected\n%s", basePath, output.String(), expected)
+	}
+	return nil
+}
+
+// Runs every program as a golden test and returns the exit code
+func runGoldenFiles(programFiles []string, update bool) int {
+	failed := 0
+	for _, programFile := range programFiles {
+		err := runGolden(programFile, update)
+		if err != nil {
+			fmt.Printf("FAIL %s\n    %s\n", programFile, err)
+			failed++
+		} else if update {
+			fmt.Printf("UPDATED %s\n", programFile)
+		} else {
+			fmt.Printf("PASS %s\n", programFile)
+		}
+	}
+
+	if failed > 0 {
+		return util.ExitTestFailure
+	}
+	return 0
+}
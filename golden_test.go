@@ -0,0 +1,24 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Update golden output files")
+
+func TestExamples(t *testing.T) {
+	programFiles, err := filepath.Glob("examples/*.peepoo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, programFile := range programFiles {
+		t.Run(filepath.Base(programFile), func(t *testing.T) {
+			if err := runGolden(programFile, *update); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -24,6 +24,10 @@ func main() {
 	verbose := flag.Int("verbose", 0, "Enable verbose mode")
 	encodeString := flag.Bool("encode", false, "Encode string")
 	decodeString := flag.Bool("decode", false, "Decode string")
+	seed := flag.Int64("seed", 0, "Seed for random numbers, random if not set")
+	golden := flag.Bool("golden", false, "Compare program output with golden .out files")
+	update := flag.Bool("update", false, "Write golden .out files instead of comparing")
+	doc := flag.Bool("doc", false, "Print the functions of a program with their doc comments")
 	flag.Parse()
 	util.LogLevel = *verbose
 
@@ -34,6 +38,16 @@ func main() {
 		inputFile = args[0]
 	}
 
+	// Golden mode runs every given program and checks its output
+	if *golden {
+		os.Exit(runGoldenFiles(args, *update))
+	}
+
+	// Test mode runs the tests found in the given files and directories
+	if len(args) > 0 && args[0] == "test" {
+		os.Exit(runTests(args[1:]))
+	}
+
 	if *encodeString {
 		if len(args) > 0 {
 			inputString := args[0]
@@ -46,7 +60,11 @@ func main() {
 	if *decodeString {
 		if len(args) > 0 {
 			inputString := args[0]
-			decoded, _ := runtime.DecodeString(inputString)
+			decoded, err := runtime.DecodeString(inputString)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(util.ExitSyntaxError)
+			}
 			fmt.Println(decoded)
 		}
 		return
@@ -60,24 +78,58 @@ func main() {
 		log.Fatalf("Failed to open program file %s\n.", inputFile)
 	}
 
+	// Everything after the program file is passed to the program
+	runtime.ProgramArgs = args[1:]
+	// Zero is a valid seed, so check whether the flag was given instead of its value
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			runtime.SetSeed(*seed)
+		}
+	})
+
+	ptree, err := parseProgram(inputFile)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(util.ExitSyntaxError)
+	}
+
+	if *doc {
+		printDocs(ptree)
+		return
+	}
+
+	if util.LogLevel > 3 {
+		parser.PrintTree(ptree, "")
+	}
+	os.Exit(runtime.RunTree(ptree))
+}
+
+// Tokenizes, parses and checks a program file
+func parseProgram(inputFile string) (*util.TreeNode[parser.ParseNode], error) {
 	totalStart := time.Now()
 
 	util.Log(1, "-Running tokenizer")
 	start := time.Now()
-	tokenDefinitions, tokens := tokenizer.Tokenize(tokenFile, inputFile)
+	tokenDefinitions, tokens, err := tokenizer.Tokenize(tokenFile, inputFile)
+	if err != nil {
+		return nil, err
+	}
 	util.Log(1, fmt.Sprintf("Tokenizer finished: %s\n", time.Since(start)))
 
 	util.Log(3, fmt.Sprintln(tokens))
 
 	util.Log(1, fmt.Sprintln("-Running parser"))
 	start = time.Now()
-	ptree := parser.Parse(tokenDefinitions, tokens, grammarFile)
+	ptree, err := parser.Parse(tokenDefinitions, tokens, grammarFile)
+	if err != nil {
+		return nil, err
+	}
 	util.Log(1, fmt.Sprintf("Parser finished: %s\n", time.Since(start)))
 
-	util.Log(1, fmt.Sprintf("-Done: %s\n", time.Since(totalStart)))
-
-	if util.LogLevel > 3 {
-		parser.PrintTree(ptree, "")
+	if err := runtime.CheckConstants(ptree); err != nil {
+		return nil, err
 	}
-	runtime.RunTree(ptree)
+
+	util.Log(1, fmt.Sprintf("-Done: %s\n", time.Since(totalStart)))
+	return ptree, nil
 }
@@ -158,31 +158,62 @@ func updateMaxLineAndColumn(line int, column int) {
 	}
 }
 
-func naiveParseRecursive(programTokens *[]tokenizer.Token, grammar *GrammarRules, currentSymbol GrammarSymbol, startSymbol GrammarSymbol, tokenIndex int) (*util.TreeNode[ParseNode], int) {
+// Parse results of non-terminals by position. The first successful rule always wins,
+// so a symbol parses the same way every time it is tried at the same token.
+type parseMemoKey struct {
+	Name       string
+	TokenIndex int
+}
+
+type parseMemoResult struct {
+	Node       *util.TreeNode[ParseNode]
+	TokenIndex int
+}
+
+type parseMemo map[parseMemoKey]parseMemoResult
+
+func naiveParseRecursive(programTokens *[]tokenizer.Token, grammar *GrammarRules, currentSymbol GrammarSymbol, startSymbol GrammarSymbol, tokenIndex int, memo parseMemo) (*util.TreeNode[ParseNode], int) {
 	// Terminals have no rules, return as leaf node
 	if tokenIndex >= len(*programTokens) {
 		return nil, tokenIndex
 	}
 
-	currentToken := (*programTokens)[tokenIndex]
 	if currentSymbol.IsTerminal {
-		if currentSymbol.Name != currentToken.Name {
-			// Terminal cannot match
-			return nil, tokenIndex
-		}
+		return parseTerminal(programTokens, currentSymbol, tokenIndex)
+	}
 
-		updateMaxLineAndColumn(currentToken.Line, currentToken.Column)
-		// Terminal can match
-		return &util.TreeNode[ParseNode]{
-			Children: nil,
-			Value: ParseNode{
-				Name:       currentToken.Name,
-				Value:      currentToken.Value,
-				IsTerminal: true,
-				Token:      &currentToken,
-			},
-		}, tokenIndex + 1
+	key := parseMemoKey{Name: currentSymbol.Name, TokenIndex: tokenIndex}
+	if result, ok := memo[key]; ok {
+		return result.Node, result.TokenIndex
 	}
+	node, nextTokenIndex := parseNonTerminal(programTokens, grammar, currentSymbol, startSymbol, tokenIndex, memo)
+	memo[key] = parseMemoResult{Node: node, TokenIndex: nextTokenIndex}
+	return node, nextTokenIndex
+}
+
+func parseTerminal(programTokens *[]tokenizer.Token, currentSymbol GrammarSymbol, tokenIndex int) (*util.TreeNode[ParseNode], int) {
+
+	currentToken := (*programTokens)[tokenIndex]
+	if currentSymbol.Name != currentToken.Name {
+		// Terminal cannot match
+		return nil, tokenIndex
+	}
+
+	updateMaxLineAndColumn(currentToken.Line, currentToken.Column)
+	// Terminal can match
+	return &util.TreeNode[ParseNode]{
+		Children: nil,
+		Value: ParseNode{
+			Name:       currentToken.Name,
+			Value:      currentToken.Value,
+			IsTerminal: true,
+			Token:      &currentToken,
+		},
+	}, tokenIndex + 1
+}
+
+func parseNonTerminal(programTokens *[]tokenizer.Token, grammar *GrammarRules, currentSymbol GrammarSymbol, startSymbol GrammarSymbol, tokenIndex int, memo parseMemo) (*util.TreeNode[ParseNode], int) {
+	currentToken := (*programTokens)[tokenIndex]
 
 	// Loop non-terminal rules and try to parse each one
 	rules := (*grammar)[currentSymbol.Name]
@@ -192,7 +223,7 @@ func naiveParseRecursive(programTokens *[]tokenizer.Token, grammar *GrammarRules
 		childTokenIndex := tokenIndex
 		for _, childSymbol := range rule {
 			var childNode *util.TreeNode[ParseNode]
-			childNode, childTokenIndex = naiveParseRecursive(programTokens, grammar, childSymbol, startSymbol, childTokenIndex)
+			childNode, childTokenIndex = naiveParseRecursive(programTokens, grammar, childSymbol, startSymbol, childTokenIndex, memo)
 			if childNode == nil {
 				// Could not create children, rule cannot apply
 				parsedAllChildren = false
@@ -227,16 +258,17 @@ func naiveParseRecursive(programTokens *[]tokenizer.Token, grammar *GrammarRules
 	return nil, tokenIndex
 }
 
-func naiveParse(programTokens *[]tokenizer.Token, grammar *GrammarRules, firstSymbol GrammarSymbol) *util.TreeNode[ParseNode] {
-	tree, _ := naiveParseRecursive(programTokens, grammar, firstSymbol, firstSymbol, 0)
+func naiveParse(programTokens *[]tokenizer.Token, grammar *GrammarRules, firstSymbol GrammarSymbol) (*util.TreeNode[ParseNode], error) {
+	MaxLine = 0
+	MaxColumn = 0
+	tree, _ := naiveParseRecursive(programTokens, grammar, firstSymbol, firstSymbol, 0, parseMemo{})
 	if tree == nil {
-		util.FatalError("Failed to parse expression", MaxLine, MaxColumn)
+		return nil, util.FormatError("Failed to parse expression", MaxLine, MaxColumn)
 	}
-	return tree
+	return tree, nil
 }
 
-func Parse(terminals *[]tokenizer.TokenDefinition, programTokens *[]tokenizer.Token, grammarFile embed.FS) *util.TreeNode[ParseNode] {
+func Parse(terminals *[]tokenizer.TokenDefinition, programTokens *[]tokenizer.Token, grammarFile embed.FS) (*util.TreeNode[ParseNode], error) {
 	grammar, firstSymbol := loadGrammarFile(grammarFile, terminals)
-	parseTree := naiveParse(programTokens, grammar, firstSymbol)
-	return parseTree
+	return naiveParse(programTokens, grammar, firstSymbol)
 }
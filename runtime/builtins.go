@@ -0,0 +1,227 @@
+package runtime
+
+import (
+	"JureBevc/peepoo/parser"
+	"JureBevc/peepoo/util"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Type code of the value of a call that returns nothing
+const TypeNothing int64 = -1
+
+// Type codes returned by the typeof built-in
+const (
+	TypeInt int64 = iota
+	TypeChar
+	TypeString
+	TypeList
+	TypeFunction
+	TypeRecord
+	TypeGenerator
+	TypeChannel
+)
+
+var typeNames = map[int64]string{
+	TypeNothing:   "nothing",
+	TypeInt:       "int",
+	TypeChar:      "char",
+	TypeString:    "string",
+	TypeList:      "list",
+	TypeFunction:  "function",
+	TypeRecord:    "record",
+	TypeGenerator: "generator",
+	TypeChannel:   "channel",
+}
+
+// String is the value of tostring. Chars are Go strings with a single rune, so strings
+// have their own type to keep a one-char string from being taken for a char.
+type String string
+
+// TypeOf returns the type code of a runtime value
+func TypeOf(value interface{}) int64 {
+	switch value.(type) {
+	case int64:
+		return TypeInt
+	case string:
+		return TypeChar
+	case String:
+		return TypeString
+	case []interface{}:
+		return TypeList
+	case *util.TreeNode[parser.ParseNode]:
+		return TypeFunction
+	case *Record:
+		return TypeRecord
+	case *Generator:
+		return TypeGenerator
+	case *Channel:
+		return TypeChannel
+	}
+	return TypeNothing
+}
+
+func conversionError(value interface{}, target string, node *util.TreeNode[parser.ParseNode]) error {
+	errorText := fmt.Sprintf("Cannot convert %s to %s", typeNames[TypeOf(value)], target)
+	return util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
+}
+
+func ToInt(value interface{}, node *util.TreeNode[parser.ParseNode]) (interface{}, error) {
+	switch TypeOf(value) {
+	case TypeInt:
+		return value, nil
+	case TypeChar:
+		r, _ := utf8.DecodeRuneInString(value.(string))
+		return int64(r), nil
+	}
+	return nil, conversionError(value, "int", node)
+}
+
+func ToChar(value interface{}, node *util.TreeNode[parser.ParseNode]) (interface{}, error) {
+	switch TypeOf(value) {
+	case TypeInt:
+		// The inverse of chartoint, ints are character codes
+		code := value.(int64)
+		if code < 0 || code > utf8.MaxRune || !utf8.ValidRune(rune(code)) {
+			errorText := fmt.Sprintf("Invalid character code %d", code)
+			return nil, util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
+		}
+		return string(rune(code)), nil
+	case TypeChar:
+		return value, nil
+	case TypeString:
+		// A string with a single char
+		if s := string(value.(String)); utf8.RuneCountInString(s) == 1 {
+			return s, nil
+		}
+	case TypeList:
+		// A list with a single char
+		if list := value.([]interface{}); len(list) == 1 && TypeOf(list[0]) == TypeChar {
+			return list[0], nil
+		}
+	}
+	return nil, conversionError(value, "char", node)
+}
+
+func ToString(value interface{}, node *util.TreeNode[parser.ParseNode]) (interface{}, error) {
+	switch TypeOf(value) {
+	case TypeInt:
+		return String(strconv.FormatInt(value.(int64), 10)), nil
+	case TypeChar, TypeString:
+		s, _ := ListToString(value)
+		return String(s), nil
+	case TypeList:
+		if s, ok := ListToString(value); ok {
+			return String(s), nil
+		}
+	}
+	return nil, conversionError(value, "string", node)
+}
+
+func ToList(value interface{}, node *util.TreeNode[parser.ParseNode]) (interface{}, error) {
+	switch TypeOf(value) {
+	case TypeInt:
+		return []interface{}{value}, nil
+	case TypeChar, TypeString:
+		s, _ := ListToString(value)
+		return StringToList(s), nil
+	case TypeList:
+		list := value.([]interface{})
+		newList := make([]interface{}, len(list))
+		copy(newList, list)
+		return newList, nil
+	}
+	return nil, conversionError(value, "list", node)
+}
+
+// FormatDecimal writes an int as a list of decimal digit chars
+func FormatDecimal(value interface{}, node *util.TreeNode[parser.ParseNode]) (interface{}, error) {
+	number, ok := value.(int64)
+	if !ok {
+		return nil, conversionError(value, "decimal digits", node)
+	}
+	return StringToList(strconv.FormatInt(number, 10)), nil
+}
+
+// FormatBinary writes an int as a list of chars spelling its peepoo binary literal
+func FormatBinary(value interface{}, node *util.TreeNode[parser.ParseNode]) (interface{}, error) {
+	number, ok := value.(int64)
+	if !ok {
+		return nil, conversionError(value, "binary", node)
+	}
+	if number < 0 {
+		errorText := fmt.Sprintf("Cannot write negative number %d as binary", number)
+		return nil, util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
+	}
+
+	binary := ""
+	for _, digit := range strconv.FormatInt(number, 2) {
+		if digit == '1' {
+			binary += "pi"
+		} else {
+			binary += "po"
+		}
+	}
+	return StringToList(binary), nil
+}
+
+var peepooBinaryRegex = regexp.MustCompile(`^(p(i|o))+$`)
+
+// ParseInt reads an int from a string or list of chars. Decimal numbers, binary numbers
+// starting with 0b and peepoo binary numbers like pipo are understood.
+func ParseInt(value interface{}, node *util.TreeNode[parser.ParseNode]) (interface{}, error) {
+	text, ok := ListToString(value)
+	if !ok {
+		return nil, conversionError(value, "int", node)
+	}
+	text = strings.TrimSpace(text)
+
+	var number int64
+	var err error
+	if peepooBinaryRegex.MatchString(text) {
+		binary := strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(text, "p", ""), "i", "1"), "o", "0")
+		number, err = strconv.ParseInt(binary, 2, 64)
+	} else if binary, isBinary := strings.CutPrefix(text, "0b"); isBinary {
+		number, err = strconv.ParseInt(binary, 2, 64)
+	} else {
+		number, err = strconv.ParseInt(text, 10, 64)
+	}
+
+	if err != nil {
+		errorText := fmt.Sprintf("Cannot parse %q as an integer", text)
+		return nil, util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
+	}
+	return number, nil
+}
+
+func RunConversion(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
+	builtinNode := node.Children[0]
+	value, err := RunValue(node.Children[1], scope)
+	if err != nil {
+		return nil, err
+	}
+
+	switch builtinNode.Value.Name {
+	case "typeof":
+		return TypeOf(value), nil
+	case "toint":
+		return ToInt(value, builtinNode)
+	case "tochar":
+		return ToChar(value, builtinNode)
+	case "tostring":
+		return ToString(value, builtinNode)
+	case "tolist":
+		return ToList(value, builtinNode)
+	case "parseint":
+		return ParseInt(value, builtinNode)
+	case "formatdecimal":
+		return FormatDecimal(value, builtinNode)
+	case "formatbinary":
+		return FormatBinary(value, builtinNode)
+	}
+
+	return nil, util.FormatError("Unknown conversion", builtinNode.Value.Token.Line, builtinNode.Value.Token.Column)
+}
@@ -0,0 +1,121 @@
+package runtime
+
+import (
+	"JureBevc/peepoo/parser"
+	"JureBevc/peepoo/util"
+	"fmt"
+)
+
+// Constants are marked with an extra scope key, which can never clash with a variable name
+func constKey(variableName string) string {
+	return "CONST " + variableName
+}
+
+func IsConstant(scope *Scope, variableName string) bool {
+	_, ok := (*scope)[constKey(variableName)]
+	return ok
+}
+
+// Returns an error if the variable in varNode is a constant and cannot be assigned
+func checkAssignable(varNode *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	if IsConstant(scope, varNode.Value.Value) {
+		errorText := fmt.Sprintf("Cannot assign to constant %s", varNode.Value.Value)
+		return util.FormatError(errorText, varNode.Value.Token.Line, varNode.Value.Token.Column)
+	}
+	return nil
+}
+
+func RunConst(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	// The marker remembers the declaration, so a declaration inside a loop can run again
+	varNode := node.Children[1]
+	if declaration, ok := (*scope)[constKey(varNode.Value.Value)]; ok && declaration != node {
+		errorText := fmt.Sprintf("Constant %s is already declared", varNode.Value.Value)
+		return util.FormatError(errorText, varNode.Value.Token.Line, varNode.Value.Token.Column)
+	}
+
+	result, err := RunMath(node.Children[3], scope)
+	if err != nil {
+		return err
+	}
+	(*scope)[varNode.Value.Value] = result
+	(*scope)[constKey(varNode.Value.Value)] = node
+	return nil
+}
+
+// Returns the var nodes that an expression node assigns to
+func assignedVars(node *util.TreeNode[parser.ParseNode]) []*util.TreeNode[parser.ParseNode] {
+	switch node.Value.Name {
+	case "ASSIGN":
+		if node.Children[1].Value.Name == "DESTRUCTURE" {
+			targets, _ := destructureTargets(node)
+			return targets
+		}
+		target := node.Children[0]
+		if target.Value.Name == "LISTACCESS" {
+			target = target.Children[0].Children[0]
+		}
+		if target.Value.Name == "var" {
+			return []*util.TreeNode[parser.ParseNode]{target}
+		}
+	case "LOOP", "FUNC":
+		return []*util.TreeNode[parser.ParseNode]{node.Children[1]}
+	case "FOREACH":
+		if len(node.Children) == 6 {
+			return []*util.TreeNode[parser.ParseNode]{node.Children[1], node.Children[2]}
+		}
+		return []*util.TreeNode[parser.ParseNode]{node.Children[1]}
+	case "TRY":
+		return []*util.TreeNode[parser.ParseNode]{node.Children[2]}
+	case "LISTAPPEND", "LISTPOP":
+		return []*util.TreeNode[parser.ParseNode]{node.Children[0]}
+	}
+	return nil
+}
+
+// CheckConstants finds assignments to constants before the program is run
+func CheckConstants(node *util.TreeNode[parser.ParseNode]) error {
+	return checkConstants(node, map[string]bool{})
+}
+
+func checkConstants(node *util.TreeNode[parser.ParseNode], constants map[string]bool) error {
+	for _, varNode := range assignedVars(node) {
+		if constants[varNode.Value.Value] {
+			errorText := fmt.Sprintf("Cannot assign to constant %s", varNode.Value.Value)
+			return util.FormatError(errorText, varNode.Value.Token.Line, varNode.Value.Token.Column)
+		}
+	}
+
+	switch node.Value.Name {
+	case "CONST":
+		varNode := node.Children[1]
+		if constants[varNode.Value.Value] {
+			errorText := fmt.Sprintf("Constant %s is already declared", varNode.Value.Value)
+			return util.FormatError(errorText, varNode.Value.Token.Line, varNode.Value.Token.Column)
+		}
+		err := checkConstants(node.Children[3], constants)
+		if err != nil {
+			return err
+		}
+		constants[varNode.Value.Value] = true
+		return nil
+	case "FUNC":
+		// Function bodies see constants declared before them, parameters shadow them
+		bodyConstants := map[string]bool{}
+		for name := range constants {
+			bodyConstants[name] = true
+		}
+		params, bodyNode := functionParams(node.Children[2])
+		for _, param := range params {
+			delete(bodyConstants, param.Node.Value.Value)
+		}
+		return checkConstants(bodyNode, bodyConstants)
+	}
+
+	for _, child := range node.Children {
+		err := checkConstants(child, constants)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
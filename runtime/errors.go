@@ -0,0 +1,161 @@
+package runtime
+
+import (
+	"JureBevc/peepoo/parser"
+	"JureBevc/peepoo/util"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ThrowError is returned by the throw keyword and carries the thrown value
+type ThrowError struct {
+	Value  interface{}
+	Line   int
+	Column int
+}
+
+func (e *ThrowError) Error() string {
+	text := fmt.Sprint(e.Value)
+	if message, ok := ListToString(e.Value); ok {
+		text = message
+	}
+	return fmt.Sprintf("ln %d col %d: Uncaught value %s", e.Line, e.Column, text)
+}
+
+// ExitError is returned by the exit built-in and stops the program, it cannot be caught
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit %d", e.Code)
+}
+
+// ExitCode prints an uncaught error and returns the exit code of the program
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	fmt.Fprintln(Stdout, err)
+	return util.ExitRuntimeError
+}
+
+// StringToList converts a Go string into a peepoo list of chars
+func StringToList(s string) []interface{} {
+	chars := []interface{}{}
+	for _, r := range s {
+		chars = append(chars, string(r))
+	}
+	return chars
+}
+
+// ListToString converts a peepoo list of chars (or a string or char) into a Go string
+func ListToString(value interface{}) (string, bool) {
+	switch s := value.(type) {
+	case String:
+		return string(s), true
+	case string:
+		return s, true
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return "", false
+	}
+
+	var builder strings.Builder
+	for _, val := range list {
+		char, ok := val.(string)
+		if !ok {
+			return "", false
+		}
+		builder.WriteString(char)
+	}
+	return builder.String(), true
+}
+
+// PositionError makes sure an error carries a source position, falling back to the position of node
+func PositionError(err error, node *util.TreeNode[parser.ParseNode]) error {
+	var peepooErr *util.Error
+	var throwErr *ThrowError
+	var exitErr *ExitError
+	if errors.As(err, &peepooErr) || errors.As(err, &throwErr) || errors.As(err, &exitErr) {
+		return err
+	}
+	return util.FormatError(err.Error(), node.Value.Token.Line, node.Value.Token.Column)
+}
+
+// Value bound to the catch variable, a list of [message line column]
+func caughtValue(err error, node *util.TreeNode[parser.ParseNode]) []interface{} {
+	var throwErr *ThrowError
+	if errors.As(err, &throwErr) {
+		return []interface{}{throwErr.Value, int64(throwErr.Line), int64(throwErr.Column)}
+	}
+
+	var peepooErr *util.Error
+	errors.As(PositionError(err, node), &peepooErr)
+	return []interface{}{StringToList(peepooErr.Text), int64(peepooErr.Line), int64(peepooErr.Column)}
+}
+
+func RunBlock(bodyNode *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	// body node has 1 child when its block end
+	for len(bodyNode.Children) > 1 {
+		err := RunExpression(bodyNode.Children[0], scope)
+		if err != nil {
+			return err
+		}
+		if ScopeIsReturning(scope) {
+			break
+		}
+		bodyNode = bodyNode.Children[1]
+	}
+	return nil
+}
+
+func RunTry(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	err := RunBlock(node.Children[1], scope)
+	var exitErr *ExitError
+	if err == nil || errors.As(err, &exitErr) || errors.Is(err, errGeneratorClosed) {
+		return err
+	}
+
+	catchVariableName := node.Children[2].Value.Value
+	if assignErr := checkAssignable(node.Children[2], scope); assignErr != nil {
+		return assignErr
+	}
+	(*scope)[catchVariableName] = caughtValue(err, node)
+	return RunBlock(node.Children[3], scope)
+}
+
+func RunThrow(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	value, err := RunMath(node.Children[1], scope)
+	if err != nil {
+		return err
+	}
+	return &ThrowError{
+		Value:  value,
+		Line:   node.Value.Token.Line,
+		Column: node.Value.Token.Column,
+	}
+}
+
+func RunExit(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	value, err := RunMath(node.Children[1], scope)
+	if err != nil {
+		return err
+	}
+	code, ok := value.(int64)
+	if !ok || code < 0 || code > 255 {
+		errorText := fmt.Sprintf("Invalid exit code %v, expected a number from 0 to 255", value)
+		return util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
+	}
+	return &ExitError{Code: int(code)}
+}
@@ -0,0 +1,84 @@
+package runtime
+
+import (
+	"JureBevc/peepoo/parser"
+	"JureBevc/peepoo/util"
+	"fmt"
+	"os"
+)
+
+// Checks and converts a file path value, used by every file built-in
+func filePath(value interface{}, node *util.TreeNode[parser.ParseNode]) (string, error) {
+	path, ok := ListToString(value)
+	if !ok || path == "" {
+		return "", util.FormatError("File path is not a list of chars", node.Value.Token.Line, node.Value.Token.Column)
+	}
+	return path, nil
+}
+
+func RunGetenv(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
+	builtinNode := node.Children[0]
+	nameValue, err := RunValue(node.Children[1], scope)
+	if err != nil {
+		return nil, err
+	}
+	name, ok := ListToString(nameValue)
+	if !ok {
+		return nil, util.FormatError(
+			"Environment variable name is not a list of chars",
+			builtinNode.Value.Token.Line,
+			builtinNode.Value.Token.Column,
+		)
+	}
+
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		errorText := fmt.Sprintf("Environment variable %s is not set", name)
+		return nil, util.FormatError(errorText, builtinNode.Value.Token.Line, builtinNode.Value.Token.Column)
+	}
+	return String(value), nil
+}
+
+func RunFileWrite(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	builtinNode := node.Children[0]
+
+	pathValue, err := RunValue(node.Children[1], scope)
+	if err != nil {
+		return err
+	}
+	path, err := filePath(pathValue, builtinNode)
+	if err != nil {
+		return err
+	}
+
+	contentValue, err := RunValue(node.Children[2], scope)
+	if err != nil {
+		return err
+	}
+	content, ok := ListToString(contentValue)
+	if !ok {
+		return util.FormatError(
+			"File content is not a list of chars",
+			builtinNode.Value.Token.Line,
+			builtinNode.Value.Token.Column,
+		)
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if builtinNode.Value.Name == "appendfile" {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		errorText := fmt.Sprintf("Failed to open file %s", path)
+		return util.FormatError(errorText, builtinNode.Value.Token.Line, builtinNode.Value.Token.Column)
+	}
+	_, err = file.WriteString(content)
+	closeErr := file.Close()
+	if err != nil || closeErr != nil {
+		errorText := fmt.Sprintf("Failed to write file %s", path)
+		return util.FormatError(errorText, builtinNode.Value.Token.Line, builtinNode.Value.Token.Column)
+	}
+	return nil
+}
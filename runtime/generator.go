@@ -0,0 +1,193 @@
+package runtime
+
+import (
+	"JureBevc/peepoo/parser"
+	"JureBevc/peepoo/util"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Scope key of the generator a function body is running in
+const generatorKey = "GEN"
+
+// Returned by yield when the program ended before the generator finished, it unwinds the generator body
+var errGeneratorClosed = errors.New("Generator is closed")
+
+// Generators with a running goroutine, they are closed when the program ends
+var liveGenerators = map[*Generator]bool{}
+var liveGeneratorsMutex sync.Mutex
+
+// Guards the consumer chains used to find generators that take their own next value
+var generatorChainMutex sync.Mutex
+
+type generatorResult struct {
+	Value interface{}
+	Done  bool
+	Err   error
+}
+
+// Generator is returned by calling a function that yields. Its body runs on its own goroutine,
+// which is paused at every yield until the next value is requested, so only one side runs at a time.
+type Generator struct {
+	Name     string
+	body     *util.TreeNode[parser.ParseNode]
+	scope    *Scope
+	results  chan generatorResult
+	resume   chan struct{}
+	done     chan struct{}
+	mutex    sync.Mutex
+	consumer *Generator
+	started  bool
+	finished bool
+}
+
+func newGenerator(name string, body *util.TreeNode[parser.ParseNode], scope *Scope) *Generator {
+	generator := &Generator{
+		Name:    name,
+		body:    body,
+		scope:   scope,
+		results: make(chan generatorResult),
+		resume:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	(*scope)[generatorKey] = generator
+	return generator
+}
+
+func (g *Generator) String() string {
+	return fmt.Sprintf("generator %s", g.Name)
+}
+
+func (g *Generator) run() {
+	err := RunBlock(g.body, g.scope)
+
+	liveGeneratorsMutex.Lock()
+	delete(liveGenerators, g)
+	liveGeneratorsMutex.Unlock()
+
+	select {
+	case g.results <- generatorResult{Done: true, Err: err}:
+	case <-g.done:
+	}
+}
+
+// Stops the goroutines of all generators that were not run to the end
+func closeGenerators() {
+	liveGeneratorsMutex.Lock()
+	defer liveGeneratorsMutex.Unlock()
+	for generator := range liveGenerators {
+		close(generator.done)
+		delete(liveGenerators, generator)
+	}
+}
+
+// Next runs the generator until it yields a value, returns false once the generator is finished.
+// The caller is the generator whose body asks for the value, or nil outside of generators.
+func (g *Generator) Next(caller *Generator) (interface{}, bool, error) {
+	// A generator waiting for itself, directly or through other generators, would never continue
+	generatorChainMutex.Lock()
+	for consumer := caller; consumer != nil; consumer = consumer.consumer {
+		if consumer == g {
+			generatorChainMutex.Unlock()
+			return nil, false, fmt.Errorf("Generator %s cannot take its own next value", g.Name)
+		}
+	}
+	generatorChainMutex.Unlock()
+
+	// Tasks sharing a generator take turns
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.finished {
+		return nil, false, nil
+	}
+
+	generatorChainMutex.Lock()
+	g.consumer = caller
+	generatorChainMutex.Unlock()
+	defer func() {
+		generatorChainMutex.Lock()
+		g.consumer = nil
+		generatorChainMutex.Unlock()
+	}()
+
+	if g.started {
+		g.resume <- struct{}{}
+	} else {
+		g.started = true
+		liveGeneratorsMutex.Lock()
+		liveGenerators[g] = true
+		liveGeneratorsMutex.Unlock()
+		go g.run()
+	}
+
+	result := <-g.results
+	if result.Done {
+		g.finished = true
+		return nil, false, result.Err
+	}
+	return result.Value, true, nil
+}
+
+// Reports whether a function body yields, yields in nested functions belong to those functions
+func isGenerator(node *util.TreeNode[parser.ParseNode]) bool {
+	for _, child := range node.Children {
+		switch child.Value.Name {
+		case "YIELD":
+			return true
+		case "FUNC":
+			continue
+		}
+		if isGenerator(child) {
+			return true
+		}
+	}
+	return false
+}
+
+func RunYield(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	generator, ok := (*scope)[generatorKey].(*Generator)
+	if !ok {
+		return util.FormatError("Cannot yield outside of a function", node.Value.Token.Line, node.Value.Token.Column)
+	}
+
+	value, err := RunMath(node.Children[1], scope)
+	if err != nil {
+		return err
+	}
+	// The generator keeps running after the value is taken, so it never shares it with the consumer
+	select {
+	case generator.results <- generatorResult{Value: copyValue(value)}:
+	case <-generator.done:
+		return errGeneratorClosed
+	}
+	select {
+	case <-generator.resume:
+	case <-generator.done:
+		return errGeneratorClosed
+	}
+	return nil
+}
+
+func RunNext(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
+	value, err := RunValue(node.Children[1], scope)
+	if err != nil {
+		return nil, err
+	}
+	generator, ok := value.(*Generator)
+	if !ok {
+		return nil, util.FormatError("Value is not a generator", node.Value.Token.Line, node.Value.Token.Column)
+	}
+
+	caller, _ := (*scope)[generatorKey].(*Generator)
+	next, ok, err := generator.Next(caller)
+	if err != nil {
+		return nil, PositionError(err, node)
+	}
+	if !ok {
+		errorText := fmt.Sprintf("Generator %s is finished", generator.Name)
+		return nil, util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
+	}
+	return next, nil
+}
@@ -0,0 +1,221 @@
+package runtime
+
+import (
+	"JureBevc/peepoo/parser"
+	"JureBevc/peepoo/util"
+	"fmt"
+)
+
+// Evaluates a single index of a chained list access and checks its bounds
+func listIndex(listValue interface{}, indexNode *util.TreeNode[parser.ParseNode], scope *Scope) ([]interface{}, int64, error) {
+	line := indexNode.Value.Token.Line
+	column := indexNode.Value.Token.Column
+
+	varList, ok := listValue.([]interface{})
+	if !ok {
+		return nil, 0, util.FormatError("Indexed value is not a list", line, column)
+	}
+
+	indexValue, err := RunValue(indexNode, scope)
+	if err != nil {
+		return nil, 0, err
+	}
+	indexInt, ok := indexValue.(int64)
+	if !ok {
+		return nil, 0, util.FormatError("List index is not an integer", line, column)
+	}
+
+	listLen := int64(len(varList))
+	if indexInt < 0 || indexInt >= listLen {
+		errorText := fmt.Sprintf("List index %d out of range [%d, %d]", indexInt, 0, listLen-1)
+		return nil, 0, util.FormatError(errorText, line, column)
+	}
+
+	return varList, indexInt, nil
+}
+
+// Reads a single list index or record field of a chained access
+func accessStep(value interface{}, stepNode *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
+	if stepNode.Children[0].Value.Name == "fieldaccess" {
+		record, fieldIndex, err := recordField(value, stepNode.Children[1])
+		if err != nil {
+			return nil, err
+		}
+		return record.Values[fieldIndex], nil
+	}
+
+	varList, indexInt, err := listIndex(value, stepNode.Children[1], scope)
+	if err != nil {
+		return nil, err
+	}
+	return varList[indexInt], nil
+}
+
+// Returns the value holding the last step of a chained access and the step nodes
+func listAccessTarget(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, []*util.TreeNode[parser.ParseNode], error) {
+	current, err := RunValue(node.Children[0], scope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stepNodes := []*util.TreeNode[parser.ParseNode]{}
+	stepNode := node.Children[1]
+	for stepNode != nil {
+		stepNodes = append(stepNodes, stepNode)
+		if len(stepNode.Children) > 2 {
+			stepNode = stepNode.Children[2]
+		} else {
+			stepNode = nil
+		}
+	}
+
+	for _, stepNode := range stepNodes[:len(stepNodes)-1] {
+		current, err = accessStep(current, stepNode, scope)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return current, stepNodes, nil
+}
+
+func RunListAccess(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
+	target, stepNodes, err := listAccessTarget(node, scope)
+	if err != nil {
+		return nil, err
+	}
+	return accessStep(target, stepNodes[len(stepNodes)-1], scope)
+}
+
+func RunListAssign(node *util.TreeNode[parser.ParseNode], value interface{}, scope *Scope) error {
+	if baseNode := node.Children[0].Children[0]; baseNode.Value.Name == "var" {
+		err := checkAssignable(baseNode, scope)
+		if err != nil {
+			return err
+		}
+	}
+
+	target, stepNodes, err := listAccessTarget(node, scope)
+	if err != nil {
+		return err
+	}
+
+	// Lists and records are shared, so writing the element also updates every value containing it
+	lastStep := stepNodes[len(stepNodes)-1]
+	if lastStep.Children[0].Value.Name == "fieldaccess" {
+		record, fieldIndex, err := recordField(target, lastStep.Children[1])
+		if err != nil {
+			return err
+		}
+		record.Values[fieldIndex] = value
+		return nil
+	}
+
+	varList, indexInt, err := listIndex(target, lastStep.Children[1], scope)
+	if err != nil {
+		return err
+	}
+	varList[indexInt] = value
+	return nil
+}
+
+// Evaluates a list base value, failing if it is not a list
+func listBase(node *util.TreeNode[parser.ParseNode], scope *Scope) ([]interface{}, error) {
+	value, err := RunValue(node, scope)
+	if err != nil {
+		return nil, err
+	}
+	varList, ok := value.([]interface{})
+	if !ok {
+		return nil, util.FormatError("Value is not a list", node.Value.Token.Line, node.Value.Token.Column)
+	}
+	return varList, nil
+}
+
+// Evaluates a list position, which may also be equal to the list length
+func listPosition(varList []interface{}, indexNode *util.TreeNode[parser.ParseNode], scope *Scope) (int64, error) {
+	line := indexNode.Value.Token.Line
+	column := indexNode.Value.Token.Column
+
+	indexValue, err := RunValue(indexNode, scope)
+	if err != nil {
+		return 0, err
+	}
+	indexInt, ok := indexValue.(int64)
+	if !ok {
+		return 0, util.FormatError("List index is not an integer", line, column)
+	}
+
+	listLen := int64(len(varList))
+	if indexInt < 0 || indexInt > listLen {
+		errorText := fmt.Sprintf("List position %d out of range [%d, %d]", indexInt, 0, listLen)
+		return 0, util.FormatError(errorText, line, column)
+	}
+	return indexInt, nil
+}
+
+func RunListSlice(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
+	varList, err := listBase(node.Children[0], scope)
+	if err != nil {
+		return nil, err
+	}
+
+	start, err := listPosition(varList, node.Children[2], scope)
+	if err != nil {
+		return nil, err
+	}
+	end, err := listPosition(varList, node.Children[3], scope)
+	if err != nil {
+		return nil, err
+	}
+	if end < start {
+		errorText := fmt.Sprintf("List slice end %d is before start %d", end, start)
+		return nil, util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
+	}
+
+	newList := make([]interface{}, end-start)
+	copy(newList, varList[start:end])
+	return newList, nil
+}
+
+func RunListInsert(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
+	varList, err := listBase(node.Children[0], scope)
+	if err != nil {
+		return nil, err
+	}
+
+	position, err := listPosition(varList, node.Children[2], scope)
+	if err != nil {
+		return nil, err
+	}
+	value, err := RunValue(node.Children[3], scope)
+	if err != nil {
+		return nil, err
+	}
+
+	newList := make([]interface{}, 0, len(varList)+1)
+	newList = append(newList, varList[:position]...)
+	newList = append(newList, value)
+	newList = append(newList, varList[position:]...)
+	return newList, nil
+}
+
+func RunListConcat(leftList []interface{}, rightMathNode *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
+	value, err := RunMath(rightMathNode, scope)
+	if err != nil {
+		return nil, err
+	}
+	rightList, ok := value.([]interface{})
+	if !ok {
+		return nil, util.FormatError(
+			"Only lists can be added to lists",
+			rightMathNode.Value.Token.Line,
+			rightMathNode.Value.Token.Column,
+		)
+	}
+
+	newList := make([]interface{}, 0, len(leftList)+len(rightList))
+	newList = append(newList, leftList...)
+	newList = append(newList, rightList...)
+	return newList, nil
+}
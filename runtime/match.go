@@ -0,0 +1,53 @@
+package runtime
+
+import (
+	"JureBevc/peepoo/parser"
+	"JureBevc/peepoo/util"
+)
+
+// Strings and lists of chars with the same text match each other
+func matchEqual(a interface{}, b interface{}) bool {
+	_, aIsInt := a.(int64)
+	_, bIsInt := b.(int64)
+	if !aIsInt && !bIsInt {
+		aString, aOk := ListToString(a)
+		bString, bOk := ListToString(b)
+		if aOk && bOk {
+			return aString == bString
+		}
+	}
+	return ValuesEqual(a, b)
+}
+
+func RunMatch(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	subject, err := RunMath(node.Children[1], scope)
+	if err != nil {
+		return err
+	}
+
+	// Arms are checked in order and only the first matching arm runs
+	armsNode := node.Children[2]
+	for {
+		switch armsNode.Children[0].Value.Name {
+		case "matchdefault":
+			return RunBlock(armsNode.Children[1], scope)
+		case "matchcase":
+			caseValue, err := RunValue(armsNode.Children[1], scope)
+			if err != nil {
+				return err
+			}
+			bodyNode := armsNode.Children[2]
+			if matchEqual(subject, caseValue) {
+				return RunBlock(bodyNode, scope)
+			}
+
+			// Body ends with the next arm
+			for len(bodyNode.Children) > 1 {
+				bodyNode = bodyNode.Children[1]
+			}
+			armsNode = bodyNode.Children[0]
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package runtime
+
+import (
+	"JureBevc/peepoo/parser"
+	"JureBevc/peepoo/util"
+	"fmt"
+	"math"
+)
+
+func intArgs(args []interface{}, node *util.TreeNode[parser.ParseNode]) ([]int64, error) {
+	numbers := []int64{}
+	for _, arg := range args {
+		number, ok := arg.(int64)
+		if !ok {
+			errorText := fmt.Sprintf("Argument is %s, expected an int", typeNames[TypeOf(arg)])
+			return nil, util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+func absInt(number int64) (int64, bool) {
+	if number == math.MinInt64 {
+		return 0, false
+	}
+	if number < 0 {
+		return -number, true
+	}
+	return number, true
+}
+
+// Adds two ints, reporting false on overflow
+func addInt(a int64, b int64) (int64, bool) {
+	result := a + b
+	if (b > 0 && result < a) || (b < 0 && result > a) {
+		return 0, false
+	}
+	return result, true
+}
+
+// Multiplies two ints, reporting false on overflow
+func mulInt(a int64, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	result := a * b
+	if result/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return result, true
+}
+
+// Raises base to a non-negative exponent, reporting false on overflow
+func powInt(base int64, exponent int64) (int64, bool) {
+	result := int64(1)
+	ok := true
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			if result, ok = mulInt(result, base); !ok {
+				return 0, false
+			}
+		}
+		exponent >>= 1
+		if exponent > 0 {
+			if base, ok = mulInt(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+// Largest int whose square is not larger than number
+func sqrtInt(number int64) int64 {
+	root := int64(math.Sqrt(float64(number)))
+	for square, ok := mulInt(root, root); !ok || square > number; square, ok = mulInt(root, root) {
+		root--
+	}
+	for square, ok := mulInt(root+1, root+1); ok && square <= number; square, ok = mulInt(root+1, root+1) {
+		root++
+	}
+	return root
+}
+
+func gcdInt(a int64, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func RunMathLibrary(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
+	builtinNode := node.Children[0]
+	line := builtinNode.Value.Token.Line
+	column := builtinNode.Value.Token.Column
+
+	args, err := builtinArgs(node, scope)
+	if err != nil {
+		return nil, err
+	}
+	numbers, err := intArgs(args, builtinNode)
+	if err != nil {
+		return nil, err
+	}
+
+	switch builtinNode.Value.Name {
+	case "abs":
+		result, ok := absInt(numbers[0])
+		if !ok {
+			return nil, util.FormatError(fmt.Sprintf("Absolute value of %d overflows", numbers[0]), line, column)
+		}
+		return result, nil
+	case "min":
+		return min(numbers[0], numbers[1]), nil
+	case "max":
+		return max(numbers[0], numbers[1]), nil
+	case "pow":
+		if numbers[1] < 0 {
+			errorText := fmt.Sprintf("Cannot raise to negative power %d", numbers[1])
+			return nil, util.FormatError(errorText, line, column)
+		}
+		result, ok := powInt(numbers[0], numbers[1])
+		if !ok {
+			errorText := fmt.Sprintf("%d to the power of %d overflows", numbers[0], numbers[1])
+			return nil, util.FormatError(errorText, line, column)
+		}
+		return result, nil
+	case "sqrt":
+		if numbers[0] < 0 {
+			errorText := fmt.Sprintf("Cannot take square root of negative number %d", numbers[0])
+			return nil, util.FormatError(errorText, line, column)
+		}
+		return sqrtInt(numbers[0]), nil
+	case "gcd":
+		result, ok := absInt(gcdInt(numbers[0], numbers[1]))
+		if !ok {
+			errorText := fmt.Sprintf("Greatest common divisor of %d and %d overflows", numbers[0], numbers[1])
+			return nil, util.FormatError(errorText, line, column)
+		}
+		return result, nil
+	}
+
+	return nil, util.FormatError("Unknown math built-in", line, column)
+}
@@ -0,0 +1,142 @@
+package runtime
+
+import (
+	"JureBevc/peepoo/parser"
+	"JureBevc/peepoo/util"
+	"fmt"
+	"strings"
+)
+
+// funcParam is a parameter of a function declaration
+type funcParam struct {
+	Node    *util.TreeNode[parser.ParseNode]
+	Default *util.TreeNode[parser.ParseNode]
+	Rest    bool
+}
+
+// Returns the parameters of a function and the node of its body
+func functionParams(funcParamNode *util.TreeNode[parser.ParseNode]) ([]funcParam, *util.TreeNode[parser.ParseNode]) {
+	params := []funcParam{}
+	for {
+		switch funcParamNode.Children[0].Value.Name {
+		case "rest":
+			params = append(params, funcParam{Node: funcParamNode.Children[1], Rest: true})
+			return params, funcParamNode.Children[3]
+		case "var":
+			if len(funcParamNode.Children) == 4 {
+				params = append(params, funcParam{Node: funcParamNode.Children[0], Default: funcParamNode.Children[2]})
+				funcParamNode = funcParamNode.Children[3]
+			} else {
+				params = append(params, funcParam{Node: funcParamNode.Children[0]})
+				funcParamNode = funcParamNode.Children[1]
+			}
+		default:
+			return params, funcParamNode.Children[1]
+		}
+	}
+}
+
+// Returns an error if required parameters follow optional ones or a parameter name repeats
+func checkParams(params []funcParam) error {
+	seen := map[string]bool{}
+	optional := false
+	for _, param := range params {
+		name := param.Node.Value.Value
+		line := param.Node.Value.Token.Line
+		column := param.Node.Value.Token.Column
+		if seen[name] {
+			return util.FormatError(fmt.Sprintf("Duplicate parameter %s", name), line, column)
+		}
+		seen[name] = true
+
+		if param.Default == nil && !param.Rest && optional {
+			errorText := fmt.Sprintf("Required parameter %s cannot follow optional parameters", name)
+			return util.FormatError(errorText, line, column)
+		}
+		optional = optional || param.Default != nil
+	}
+	return nil
+}
+
+// Signature of a function declaration, optional parameters are in brackets and the rest parameter ends with ...
+func declaredSignature(funcVariableName string, params []funcParam) string {
+	names := []string{}
+	for _, param := range params {
+		name := param.Node.Value.Value
+		if param.Default != nil {
+			name = "[" + name + "]"
+		} else if param.Rest {
+			name += "..."
+		}
+		names = append(names, name)
+	}
+	return fmt.Sprintf("%s(%s)", funcVariableName, strings.Join(names, " "))
+}
+
+// Signature of a function call, made of the types of its arguments
+func callSignature(funcVariableName string, args []interface{}) string {
+	types := []string{}
+	for _, arg := range args {
+		types = append(types, typeNames[TypeOf(arg)])
+	}
+	return fmt.Sprintf("%s(%s)", funcVariableName, strings.Join(types, " "))
+}
+
+// Binds call arguments to the parameters of a function in the function scope,
+// missing optional parameters get their default value and the rest parameter collects extra arguments
+func bindParams(
+	funcVariableName string,
+	params []funcParam,
+	args []interface{},
+	funcScope *Scope,
+	node *util.TreeNode[parser.ParseNode],
+) error {
+	minArgs := 0
+	maxArgs := 0
+	for _, param := range params {
+		if param.Rest {
+			maxArgs = -1
+			break
+		}
+		if param.Default == nil {
+			minArgs++
+		}
+		maxArgs++
+	}
+
+	if len(args) < minArgs || (maxArgs >= 0 && len(args) > maxArgs) {
+		errorText := fmt.Sprintf(
+			"Wrong number of arguments, %s called as %s",
+			declaredSignature(funcVariableName, params),
+			callSignature(funcVariableName, args),
+		)
+		return util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
+	}
+
+	for i, param := range params {
+		var value interface{}
+		switch {
+		case param.Rest:
+			rest := []interface{}{}
+			if i < len(args) {
+				rest = append(rest, args[i:]...)
+			}
+			value = rest
+		case i < len(args):
+			value = args[i]
+		default:
+			// Defaults are evaluated on every call and can use earlier parameters
+			defaultValue, err := RunMath(param.Default, funcScope)
+			if err != nil {
+				return err
+			}
+			value = defaultValue
+		}
+
+		// Parameters are new variables, even if the caller has a constant with the same name
+		varName := param.Node.Value.Value
+		(*funcScope)[varName] = value
+		delete(*funcScope, constKey(varName))
+	}
+	return nil
+}
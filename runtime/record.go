@@ -0,0 +1,104 @@
+package runtime
+
+import (
+	"JureBevc/peepoo/parser"
+	"JureBevc/peepoo/util"
+	"fmt"
+	"strings"
+)
+
+// Record is a value of a declared record type, its values are kept in field declaration order
+type Record struct {
+	Name   string
+	Fields []string
+	Values []interface{}
+}
+
+func (r *Record) String() string {
+	var builder strings.Builder
+	builder.WriteString(r.Name)
+	builder.WriteString("{")
+	for i, field := range r.Fields {
+		if i > 0 {
+			builder.WriteString(" ")
+		}
+		builder.WriteString(fmt.Sprintf("%s:%v", field, r.Values[i]))
+	}
+	builder.WriteString("}")
+	return builder.String()
+}
+
+// Record types are stored under an extra scope key, so they never clash with variables
+func recordKey(recordName string) string {
+	return "RECORD " + recordName
+}
+
+func RunRecord(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	recordName := node.Children[1].Value.Value
+	fields := []string{}
+	fieldNode := node.Children[2]
+	for fieldNode.Children[0].Value.Name == "var" {
+		nameNode := fieldNode.Children[0]
+		for _, field := range fields {
+			if field == nameNode.Value.Value {
+				errorText := fmt.Sprintf("Record %s has duplicate field %s", recordName, field)
+				return util.FormatError(errorText, nameNode.Value.Token.Line, nameNode.Value.Token.Column)
+			}
+		}
+		fields = append(fields, nameNode.Value.Value)
+		fieldNode = fieldNode.Children[1]
+	}
+
+	(*scope)[recordKey(recordName)] = fields
+	return nil
+}
+
+func RunRecordNew(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
+	nameNode := node.Children[1]
+	fields, ok := (*scope)[recordKey(nameNode.Value.Value)].([]string)
+	if !ok {
+		errorText := fmt.Sprintf("Undefined record %s", nameNode.Value.Value)
+		return nil, util.FormatError(errorText, nameNode.Value.Token.Line, nameNode.Value.Token.Column)
+	}
+
+	values := []interface{}{}
+	argNode := node.Children[2]
+	for argNode.Children[0].Value.Name != "recordnew" {
+		value, err := RunMath(argNode.Children[0], scope)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		argNode = argNode.Children[1]
+	}
+
+	if len(values) != len(fields) {
+		errorText := fmt.Sprintf(
+			"Record %s has %d fields, got %d values",
+			nameNode.Value.Value, len(fields), len(values),
+		)
+		return nil, util.FormatError(errorText, nameNode.Value.Token.Line, nameNode.Value.Token.Column)
+	}
+
+	return &Record{Name: nameNode.Value.Value, Fields: fields, Values: values}, nil
+}
+
+// Finds the position of a record field, fieldNode is the field name
+func recordField(value interface{}, fieldNode *util.TreeNode[parser.ParseNode]) (*Record, int, error) {
+	line := fieldNode.Value.Token.Line
+	column := fieldNode.Value.Token.Column
+
+	record, ok := value.(*Record)
+	if !ok {
+		errorText := fmt.Sprintf("Cannot access field %s of a value that is not a record", fieldNode.Value.Value)
+		return nil, 0, util.FormatError(errorText, line, column)
+	}
+
+	for i, field := range record.Fields {
+		if field == fieldNode.Value.Value {
+			return record, i, nil
+		}
+	}
+	errorText := fmt.Sprintf("Record %s has no field %s", record.Name, fieldNode.Value.Value)
+	return nil, 0, util.FormatError(errorText, line, column)
+}
@@ -5,21 +5,42 @@ import (
 	"JureBevc/peepoo/util"
 	"bufio"
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 type Scope map[string]interface{}
 
-var temporaryVars int = 0
+// Shared so that input buffered by one read is not lost for the next one
+var stdinReader = bufio.NewReader(os.Stdin)
 
+// Stdout is where programs print to
+var Stdout io.Writer = os.Stdout
+
+// Tasks print and read input one at a time
+var outputMutex sync.Mutex
+var stdinMutex sync.Mutex
+
+// SetStdin changes where programs read input from
+func SetStdin(reader io.Reader) {
+	stdinReader = bufio.NewReader(reader)
+}
+
+// Command line arguments given after the program file
+var ProgramArgs []string
+
+// CopyScope copies the variables of a scope for a function call, a return in progress is not copied
 func CopyScope(scope *Scope) *Scope {
 	newScope := Scope{}
 	for key, val := range *scope {
 		newScope[key] = val
 	}
+	delete(newScope, "RET")
 	return &newScope
 }
 
@@ -31,66 +52,103 @@ func ScopeIsReturning(scope *Scope) bool {
 	return false
 }
 
-func EncodeString(s string) string {
-	var base5Map = []string{"pa", "pe", "pi", "po", "pu"}
-	var builder strings.Builder
-	for i, c := range []byte(s) {
-		val := int(c)
-		var digits [4]int
+// Chars are written as base 5 digits, one syllable per digit
+var base5Map = []string{"pa", "pe", "pi", "po", "pu"}
 
-		// Convert to base 5, right-aligned to 4 digits
-		for j := 3; j >= 0; j-- {
-			digits[j] = val % 5
-			val /= 5
-		}
+// Short chars have 4 digits, long chars start with longCharPrefix and have 9 digits to cover all of Unicode
+const (
+	shortCharDigits = 4
+	longCharDigits  = 9
+	longCharPrefix  = "pu"
+	maxShortChar    = 624
+)
 
-		for _, d := range digits {
-			builder.WriteString(base5Map[d])
-		}
+func encodeRune(builder *strings.Builder, r rune) {
+	digitCount := shortCharDigits
+	if r > maxShortChar {
+		digitCount = longCharDigits
+		builder.WriteString(longCharPrefix)
+	}
+
+	// Convert to base 5, right-aligned to the digit count
+	val := int(r)
+	digits := make([]int, digitCount)
+	for j := digitCount - 1; j >= 0; j-- {
+		digits[j] = val % 5
+		val /= 5
+	}
 
-		if i < len(s)-1 {
+	for _, d := range digits {
+		builder.WriteString(base5Map[d])
+	}
+}
+
+func EncodeString(s string) string {
+	var builder strings.Builder
+	for i, r := range []rune(s) {
+		if i > 0 {
 			builder.WriteByte(' ')
 		}
+		encodeRune(&builder, r)
 	}
 
 	return builder.String()
 }
 
-func DecodeString(encoded string) (string, error) {
+func decodeRune(word string) (rune, error) {
 	revMap := map[string]int{
 		"pa": 0, "pe": 1, "pi": 2, "po": 3, "pu": 4,
 	}
 
-	words := strings.Split(encoded, " ")
-	var result strings.Builder
+	digits := word
+	if len(word) == 2*(longCharDigits+1) && strings.HasPrefix(word, longCharPrefix) {
+		digits = word[len(longCharPrefix):]
+	} else if len(word) != 2*shortCharDigits {
+		return 0, fmt.Errorf("failed to decode word %s", word)
+	}
 
-	for _, word := range words {
-		if len(word) != 8 {
-			return "", fmt.Errorf("failed to decode word %s", word)
+	val := 0
+	for i := 0; i < len(digits); i += 2 {
+		symbol := digits[i : i+2]
+		digit, ok := revMap[symbol]
+		if !ok {
+			return 0, fmt.Errorf("failed to decode symbol %s", symbol)
 		}
+		val = val*5 + digit
+	}
 
-		val := 0
-		for i := 0; i < 8; i += 2 {
-			symbol := word[i : i+2]
-			digit, ok := revMap[symbol]
-			if !ok {
-				return "", fmt.Errorf("failed to decode symbol %s", symbol)
-			}
-			val = val*5 + digit
-		}
+	if val > utf8.MaxRune || !utf8.ValidRune(rune(val)) {
+		return 0, fmt.Errorf("failed to decode word %s, %d is not a character code", word, val)
+	}
+	return rune(val), nil
+}
+
+func DecodeString(encoded string) (string, error) {
+	words := strings.Split(encoded, " ")
+	var result strings.Builder
 
-		err := result.WriteByte(byte(val))
+	for _, word := range words {
+		r, err := decodeRune(word)
 		if err != nil {
 			return "", err
 		}
+		result.WriteRune(r)
 	}
 
 	return result.String(), nil
 }
 
 func RunAssign(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	if node.Children[1].Value.Name == "DESTRUCTURE" {
+		return RunDestructure(node, scope)
+	}
+
 	switch node.Children[0].Value.Name {
 	case "var":
+		err := checkAssignable(node.Children[0], scope)
+		if err != nil {
+			return err
+		}
 		variableName := node.Children[0].Value.Value
 		valueNode := node.Children[2]
 		result, err := RunMath(valueNode, scope)
@@ -99,54 +157,79 @@ func RunAssign(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
 		}
 		(*scope)[variableName] = result
 	case "LISTACCESS":
-		variableName := node.Children[0].Value.Value
 		valueNode := node.Children[2]
 		result, err := RunMath(valueNode, scope)
 		if err != nil {
 			return err
 		}
-		accessNode := node.Children[0]
-		if varValue, ok := (*scope)[accessNode.Children[0].Value.Value]; ok {
-			varList := varValue.([]interface{})
-			indexValue, err := RunValue(accessNode.Children[2], scope)
-			if err != nil {
-				return err
-			}
-			indexInt, ok := indexValue.(int64)
-			if !ok {
-				return util.FormatError(
-					"List index not an integer",
-					accessNode.Value.Token.Line,
-					accessNode.Value.Token.Column,
-				)
-			}
-			varList[indexInt] = result
-			(*scope)[variableName] = varList
+		return RunListAssign(node.Children[0], result, scope)
+	}
+	return nil
+}
+
+// Returns the variables of a destructuring assignment and the node of the assigned value
+func destructureTargets(node *util.TreeNode[parser.ParseNode]) ([]*util.TreeNode[parser.ParseNode], *util.TreeNode[parser.ParseNode]) {
+	targets := []*util.TreeNode[parser.ParseNode]{node.Children[0]}
+	destructureNode := node.Children[1]
+	for destructureNode.Children[1].Value.Name == "DESTRUCTURE" {
+		targets = append(targets, destructureNode.Children[0])
+		destructureNode = destructureNode.Children[1]
+	}
+	targets = append(targets, destructureNode.Children[0])
+	return targets, destructureNode.Children[2]
+}
+
+func RunDestructure(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	targets, valueNode := destructureTargets(node)
+	for _, target := range targets {
+		err := checkAssignable(target, scope)
+		if err != nil {
+			return err
 		}
 	}
+
+	result, err := RunMath(valueNode, scope)
+	if err != nil {
+		return err
+	}
+	if s, ok := result.(String); ok {
+		result = StringToList(string(s))
+	}
+	values, ok := result.([]interface{})
+	if !ok {
+		return util.FormatError("Only lists can be unpacked", node.Value.Token.Line, node.Value.Token.Column)
+	}
+	if len(values) != len(targets) {
+		errorText := fmt.Sprintf("Cannot unpack %d values into %d variables", len(values), len(targets))
+		return util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
+	}
+
+	for i, target := range targets {
+		(*scope)[target.Value.Value] = values[i]
+	}
 	return nil
 }
 
 func RunValue(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
-	if node.Value.Name == "VALUE" {
+	// List bases and list indexes are restricted kinds of values
+	if node.Value.Name == "VALUE" || node.Value.Name == "LISTBASE" || node.Value.Name == "INDEX" {
 		firstChild := node.Children[0]
 		switch firstChild.Value.Name {
 		case "var":
 			if varValue, ok := (*scope)[firstChild.Value.Value]; ok {
 				return varValue, nil
-			} else {
-				util.FatalError(
-					fmt.Sprintf("Undefined variable %s", firstChild.Value.Value),
-					firstChild.Value.Token.Line,
-					firstChild.Value.Token.Column,
-				)
 			}
+			return nil, util.FormatError(
+				fmt.Sprintf("Undefined variable %s", firstChild.Value.Value),
+				firstChild.Value.Token.Line,
+				firstChild.Value.Token.Column,
+			)
 		case "binary":
 			binaryStr := strings.ReplaceAll(firstChild.Value.Value, "p", "")
 			binaryStr = strings.ReplaceAll(strings.ReplaceAll(binaryStr, "i", "1"), "o", "0")
 			val, err := strconv.ParseInt(binaryStr, 2, 64)
 			if err != nil {
-				util.FatalError(
+				return nil, util.FormatError(
 					fmt.Sprintf("Failed to parse binary number from %s", firstChild.Value.Value),
 					firstChild.Value.Token.Line,
 					firstChild.Value.Token.Column,
@@ -154,35 +237,23 @@ func RunValue(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{},
 			}
 			return val, nil
 		case "char":
-			return DecodeString(firstChild.Value.Value)
+			r, err := decodeRune(firstChild.Value.Value)
+			if err != nil {
+				return nil, util.FormatError(err.Error(), firstChild.Value.Token.Line, firstChild.Value.Token.Column)
+			}
+			return string(r), nil
 		case "FUNCCALL":
 			return RunFuncCall(firstChild, scope)
 		case "LIST":
 			return ParseList(firstChild, scope)
+		case "RECORDNEW":
+			return RunRecordNew(firstChild, scope)
 		case "LISTACCESS":
-			if varValue, ok := (*scope)[firstChild.Children[0].Value.Value]; ok {
-				varList := varValue.([]interface{})
-				indexValue, err := RunValue(firstChild.Children[2], scope)
-				if err != nil {
-					return nil, err
-				}
-				indexInt, ok := indexValue.(int64)
-				if !ok {
-					return nil, util.FormatError(
-						"List index is not an integer",
-						firstChild.Value.Token.Line,
-						firstChild.Value.Token.Column,
-					)
-				}
-				listLen := int64(len(varList))
-				if indexInt < 0 || indexInt >= listLen {
-					errorText := fmt.Sprintf("List index %d out of range [%d, %d]", indexInt, 0, listLen-1)
-					return nil, util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
-				}
-				return varList[indexInt], nil
-			}
-			errorText := fmt.Sprintf("Failed to access list %s", firstChild.Value.Value)
-			util.FatalError(errorText, node.Value.Token.Line, node.Value.Token.Column)
+			return RunListAccess(firstChild, scope)
+		case "LISTSLICE":
+			return RunListSlice(firstChild, scope)
+		case "LISTINSERT":
+			return RunListInsert(firstChild, scope)
 		case "LISTPOP":
 			return RunListPop(firstChild, scope)
 		case "LISTLEN":
@@ -198,49 +269,74 @@ func RunValue(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{},
 				return int64(len(varList)), nil
 			}
 		case "readinput":
-			reader := bufio.NewReader(os.Stdin)
-			data, err := reader.ReadString('\n')
-			if err != nil {
+			stdinMutex.Lock()
+			data, err := stdinReader.ReadString('\n')
+			stdinMutex.Unlock()
+			if err != nil && (err != io.EOF || data == "") {
 				return nil, err
 			}
-			data = strings.TrimSpace(data)
-			var chars []interface{}
-			for _, r := range data {
-				chars = append(chars, string(r))
-			}
-			return chars, nil
+			return StringToList(strings.TrimSpace(data)), nil
 		case "readfile":
 			if varValue, ok := (*scope)[node.Children[1].Value.Value]; ok {
-				listValue := varValue.([]interface{})
-				varString := ""
-				for _, val := range listValue {
-					varString = varString + val.(string)
+				path, err := filePath(varValue, node)
+				if err != nil {
+					return nil, err
 				}
-				data, _ := os.ReadFile(varString)
-				outString := string(data)
-				var chars []interface{}
-				for _, r := range outString {
-					chars = append(chars, string(r))
+				data, err := os.ReadFile(path)
+				if err != nil {
+					errorText := fmt.Sprintf("Failed to read file %s", path)
+					return nil, util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
 				}
-
-				return chars, nil
+				return StringToList(string(data)), nil
 			}
-			errorText := fmt.Sprintf("Failed to read file %s\n", firstChild.Children[1].Value.Value)
-			util.FatalError(errorText, node.Value.Token.Line, node.Value.Token.Column)
+			errorText := fmt.Sprintf("Failed to read file %s", node.Children[1].Value.Value)
+			return nil, util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
+		case "typeof", "tostring", "tolist", "toint", "tochar", "formatdecimal", "formatbinary", "parseint":
+			return RunConversion(node, scope)
+		case "args":
+			args := []interface{}{}
+			for _, arg := range ProgramArgs {
+				args = append(args, String(arg))
+			}
+			return args, nil
+		case "getenv":
+			return RunGetenv(node, scope)
+		case "sort", "sortby", "reverse", "contains", "indexof", "join", "split", "map", "filter", "reduce":
+			return RunListLibrary(node, scope)
+		case "abs", "min", "max", "pow", "sqrt", "gcd":
+			return RunMathLibrary(node, scope)
+		case "clock":
+			return clockMillis(), nil
+		case "walltime":
+			return wallTimeMillis(), nil
+		case "random":
+			return RunRandom(node, scope)
+		case "next":
+			return RunNext(node, scope)
+		case "channel", "receive":
+			return RunChannel(node, scope)
 		case "chartoint":
 			secondChild := node.Children[1]
 			if secondChild.Value.Name == "var" {
 				if varValue, ok := (*scope)[secondChild.Value.Value]; ok {
-					charVal := varValue.(string)
-					return int64(rune(charVal[0])), nil
+					charVal, ok := varValue.(string)
+					if !ok || charVal == "" {
+						return nil, util.FormatError(
+							fmt.Sprintf("Variable %s is not a char", secondChild.Value.Value),
+							secondChild.Value.Token.Line,
+							secondChild.Value.Token.Column,
+						)
+					}
+					r, _ := utf8.DecodeRuneInString(charVal)
+					return int64(r), nil
 				}
 			}
 			if secondChild.Value.Name == "char" {
-				charVal, err := DecodeString(secondChild.Value.Value)
+				r, err := decodeRune(secondChild.Value.Value)
 				if err != nil {
-					return nil, err
+					return nil, util.FormatError(err.Error(), secondChild.Value.Token.Line, secondChild.Value.Token.Column)
 				}
-				return int64(rune(charVal[0])), nil
+				return int64(r), nil
 			}
 		}
 
@@ -296,97 +392,99 @@ func RunMath(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{},
 			if err != nil {
 				return nil, err
 			}
-			leftValue, ok := result.(int64)
-			if !ok {
-				util.FatalError("Invalid value type", node.Children[0].Value.Token.Line, node.Children[0].Value.Token.Column)
-			}
-
-			operator := node.Children[1].Children[0].Value.Name
-			rightMathNode := node.Children[1].Children[1]
-			switch operator {
-			case "plus":
-				val, err := RunMath(rightMathNode, scope)
-				if err != nil {
-					return nil, err
-				}
-				rightValue, ok := val.(int64)
-				if !ok {
-					util.FatalError(
-						"Invalid value type",
-						node.Children[0].Value.Token.Line,
-						node.Children[0].Value.Token.Column,
-					)
-				}
-				return leftValue + rightValue, nil
-			case "minus":
-				val, err := RunMath(rightMathNode, scope)
-				if err != nil {
-					return nil, err
-				}
-				rightValue, ok := val.(int64)
-				if !ok {
-					util.FatalError(
-						"Invalid value type",
-						node.Children[0].Value.Token.Line,
-						node.Children[0].Value.Token.Column,
-					)
-				}
-				return leftValue - rightValue, nil
-			case "multiply":
-				val, err := RunValue(rightMathNode.Children[0], scope)
-				if err != nil {
-					return nil, err
-				}
-				rightValue, ok := val.(int64)
-				if !ok {
-					util.FatalError(
-						"Invalid value type",
-						node.Children[0].Value.Token.Line,
-						node.Children[0].Value.Token.Column,
-					)
-				}
-				newValue := leftValue * rightValue
-
-				temporaryVars += 1
-				tmpVarName := fmt.Sprintf("TMP%d", temporaryVars)
+			return runOperation(result, node.Children[0], node.Children[1], scope)
+		}
+	}
 
-				newRightNode := parser.CopyTree(rightMathNode)
+	return nil, util.FormatError(
+		"Failed to run math expression",
+		node.Value.Token.Line,
+		node.Value.Token.Column,
+	)
+}
 
-				newRightNode.Children[0].Children[0].Value.Name = "var"
-				newRightNode.Children[0].Children[0].Value.Value = tmpVarName
-				(*scope)[tmpVarName] = newValue
+// Applies an operator to an already evaluated left value and the rest of the expression.
+// Multiplying and dividing only take the next value, the result is then used as the left value
+// of the rest of the expression, adding and subtracting take the whole rest of the expression.
+func runOperation(
+	result interface{},
+	leftNode *util.TreeNode[parser.ParseNode],
+	opMathNode *util.TreeNode[parser.ParseNode],
+	scope *Scope,
+) (interface{}, error) {
+	operator := opMathNode.Children[0].Value.Name
+	rightMathNode := opMathNode.Children[1]
+
+	// Adding lists concatenates them
+	if leftList, ok := result.([]interface{}); ok && operator == "plus" {
+		return RunListConcat(leftList, rightMathNode, scope)
+	}
 
-				return RunMath(newRightNode, scope)
-			case "divide":
-				val, err := RunValue(rightMathNode.Children[0], scope)
-				if err != nil {
-					return nil, err
-				}
-				rightValue, ok := val.(int64)
-				if !ok {
-					util.FatalError(
-						"Invalid value type",
-						node.Children[0].Value.Token.Line,
-						node.Children[0].Value.Token.Column,
-					)
-				}
-				newValue := leftValue / rightValue
+	leftValue, ok := result.(int64)
+	if !ok {
+		return nil, util.FormatError(
+			"Invalid value type",
+			leftNode.Value.Token.Line,
+			leftNode.Value.Token.Column,
+		)
+	}
 
-				temporaryVars += 1
-				tmpVarName := fmt.Sprintf("TMP%d", temporaryVars)
-				rightMathNode.Children[0].Children[0].Value.Name = "var"
-				rightMathNode.Children[0].Children[0].Value.Value = tmpVarName
-				(*scope)[tmpVarName] = newValue
+	switch operator {
+	case "plus", "minus":
+		val, err := RunMath(rightMathNode, scope)
+		if err != nil {
+			return nil, err
+		}
+		rightValue, ok := val.(int64)
+		if !ok {
+			return nil, util.FormatError(
+				"Invalid value type",
+				leftNode.Value.Token.Line,
+				leftNode.Value.Token.Column,
+			)
+		}
+		if operator == "plus" {
+			return leftValue + rightValue, nil
+		}
+		return leftValue - rightValue, nil
+	case "multiply", "divide":
+		val, err := RunValue(rightMathNode.Children[0], scope)
+		if err != nil {
+			return nil, err
+		}
+		rightValue, ok := val.(int64)
+		if !ok {
+			return nil, util.FormatError(
+				"Invalid value type",
+				leftNode.Value.Token.Line,
+				leftNode.Value.Token.Column,
+			)
+		}
 
-				return RunMath(rightMathNode, scope)
+		var newValue int64
+		if operator == "multiply" {
+			newValue = leftValue * rightValue
+		} else {
+			if rightValue == 0 {
+				return nil, util.FormatError(
+					"Division by zero",
+					opMathNode.Children[0].Value.Token.Line,
+					opMathNode.Children[0].Value.Token.Column,
+				)
 			}
+			newValue = leftValue / rightValue
 		}
+
+		if len(rightMathNode.Children) == 2 {
+			return runOperation(newValue, rightMathNode.Children[0], rightMathNode.Children[1], scope)
+		}
+		return newValue, nil
 	}
 
 	return nil, util.FormatError(
 		"Failed to run math expression",
-		node.Value.Token.Line,
-		node.Value.Token.Column,
+		opMathNode.Value.Token.Line,
+		opMathNode.Value.Token.Column,
 	)
 }
 
@@ -427,6 +525,10 @@ func RunLoop(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
 
 	varNode := node.Children[1]
 	variableName := varNode.Value.Value
+	err := checkAssignable(varNode, scope)
+	if err != nil {
+		return err
+	}
 
 	startValue, err := RunMath(node.Children[2], scope)
 	if err != nil {
@@ -440,7 +542,7 @@ func RunLoop(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
 
 	mathValueStart, ok := startValue.(int64)
 	if !ok {
-		util.FatalError(
+		return util.FormatError(
 			"Invalid value type for loop start value",
 			node.Children[0].Value.Token.Line,
 			node.Children[0].Value.Token.Column,
@@ -448,30 +550,153 @@ func RunLoop(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
 	}
 	mathValueStop, ok := stopValue.(int64)
 	if !ok {
-		util.FatalError(
+		return util.FormatError(
 			"Invalid value type for loop stop value",
 			node.Children[0].Value.Token.Line,
 			node.Children[0].Value.Token.Column,
 		)
 	}
 
+	var mathValueStep int64 = 1
+	bodyNode := node.Children[4]
+	if len(node.Children) == 7 {
+		stepValue, err := RunMath(node.Children[5], scope)
+		if err != nil {
+			return err
+		}
+		mathValueStep, ok = stepValue.(int64)
+		if !ok {
+			return util.FormatError(
+				"Invalid value type for loop step value",
+				node.Children[4].Value.Token.Line,
+				node.Children[4].Value.Token.Column,
+			)
+		}
+		if mathValueStep == 0 {
+			return util.FormatError(
+				"Loop step value cannot be zero",
+				node.Children[4].Value.Token.Line,
+				node.Children[4].Value.Token.Column,
+			)
+		}
+		bodyNode = node.Children[6]
+	}
+
+	// Negative steps count down until the stop value is reached
 	currentValue := mathValueStart
-	for currentValue < mathValueStop {
+	for (mathValueStep > 0 && currentValue < mathValueStop) || (mathValueStep < 0 && currentValue > mathValueStop) {
 		(*scope)[variableName] = currentValue
-		bodyNode := node.Children[4]
 
-		// body node has 1 child when its ifend
-		for len(bodyNode.Children) > 1 {
-			err := RunExpression(bodyNode.Children[0], scope)
+		err := RunBlock(bodyNode, scope)
+		if err != nil {
+			return err
+		}
+		if ScopeIsReturning(scope) {
+			break
+		}
+		// A step past the largest or smallest int is also past the stop value
+		nextValue, ok := addInt(currentValue, mathValueStep)
+		if !ok {
+			break
+		}
+		currentValue = nextValue
+	}
+
+	return nil
+}
+
+func RunForEach(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	indexVariableName := ""
+	elementVariableName := node.Children[1].Value.Value
+	listNode := node.Children[2]
+	bodyNode := node.Children[3]
+	if len(node.Children) == 6 {
+		indexVariableName = node.Children[1].Value.Value
+		elementVariableName = node.Children[2].Value.Value
+		listNode = node.Children[4]
+		bodyNode = node.Children[5]
+	}
+	for _, varNode := range assignedVars(node) {
+		err := checkAssignable(varNode, scope)
+		if err != nil {
+			return err
+		}
+	}
+
+	value, err := RunValue(listNode, scope)
+	if err != nil {
+		return err
+	}
+
+	// Runs the loop body for one element, returns true if the loop should stop
+	runElement := func(i int, element interface{}) (bool, error) {
+		if indexVariableName != "" {
+			(*scope)[indexVariableName] = int64(i)
+		}
+		(*scope)[elementVariableName] = element
+
+		err := RunBlock(bodyNode, scope)
+		if err != nil {
+			return true, err
+		}
+		return ScopeIsReturning(scope), nil
+	}
+
+	// Generators and channels are consumed one value per iteration
+	var next func() (interface{}, bool, error)
+	switch source := value.(type) {
+	case *Generator:
+		caller, _ := (*scope)[generatorKey].(*Generator)
+		next = func() (interface{}, bool, error) {
+			element, ok, err := source.Next(caller)
 			if err != nil {
+				return nil, false, PositionError(err, node.Children[0])
+			}
+			return element, ok, nil
+		}
+	case *Channel:
+		next = func() (interface{}, bool, error) {
+			element, ok, err := source.Receive()
+			if err != nil {
+				return nil, false, PositionError(err, node.Children[0])
+			}
+			return element, ok, nil
+		}
+	}
+	if next != nil {
+		for i := 0; ; i++ {
+			element, ok, err := next()
+			if err != nil || !ok {
 				return err
 			}
-			if ScopeIsReturning(scope) {
-				break
+			stop, err := runElement(i, element)
+			if stop {
+				return err
 			}
-			bodyNode = bodyNode.Children[1]
 		}
-		currentValue += 1
+	}
+
+	// Iterate over a copy, changes to the list inside the loop are not seen until the loop ends
+	var elements []interface{}
+	switch listValue := value.(type) {
+	case []interface{}:
+		elements = make([]interface{}, len(listValue))
+		copy(elements, listValue)
+	case String:
+		elements = StringToList(string(listValue))
+	default:
+		return util.FormatError(
+			"Invalid value type for for-each loop, expected a list",
+			node.Children[0].Value.Token.Line,
+			node.Children[0].Value.Token.Column,
+		)
+	}
+
+	for i, element := range elements {
+		stop, err := runElement(i, element)
+		if stop {
+			return err
+		}
 	}
 
 	return nil
@@ -480,6 +705,15 @@ func RunLoop(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
 func RunFunc(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
 	funcVariableName := node.Children[1].Value.Value
 	funcParamNode := node.Children[2]
+	err := checkAssignable(node.Children[1], scope)
+	if err != nil {
+		return err
+	}
+	params, _ := functionParams(funcParamNode)
+	err = checkParams(params)
+	if err != nil {
+		return err
+	}
 	(*scope)[funcVariableName] = funcParamNode
 	return nil
 }
@@ -487,6 +721,7 @@ func RunFunc(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
 func RunReturn(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
 	if len(node.Children) == 1 {
 		(*scope)["RET"] = nil
+		return nil
 	}
 
 	mathNode := node.Children[1]
@@ -494,47 +729,95 @@ func RunReturn(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
 	if err != nil {
 		return err
 	}
-	(*scope)["RET"] = value
+	if len(node.Children) == 2 {
+		(*scope)["RET"] = value
+		return nil
+	}
+
+	// Several return values are returned as a list
+	values := []interface{}{value}
+	mathNode = node.Children[2]
+	valuesNode := node.Children[3]
+	for {
+		value, err := RunMath(mathNode, scope)
+		if err != nil {
+			return err
+		}
+		values = append(values, value)
+		if valuesNode.Children[0].Value.Name == "return" {
+			break
+		}
+		mathNode = valuesNode.Children[0]
+		valuesNode = valuesNode.Children[1]
+	}
+	(*scope)["RET"] = values
 	return nil
 }
 
-func RunFuncCall(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
+// Finds the function of a function call and evaluates its arguments
+func functionCall(node *util.TreeNode[parser.ParseNode], scope *Scope) (
+	string,
+	*util.TreeNode[parser.ParseNode],
+	[]interface{},
+	error,
+) {
 	funcVariableName := node.Children[1].Value.Value
-	funcParamNode := (*scope)[funcVariableName].(*util.TreeNode[parser.ParseNode])
-
-	funcParamNames := []string{}
-	for funcParamNode.Children[1].Value.Name != "FUNCBODY" {
-		varName := funcParamNode.Children[0].Value.Value
-		funcParamNames = append(funcParamNames, varName)
-		funcParamNode = funcParamNode.Children[1]
+	funcParamNode, ok := (*scope)[funcVariableName].(*util.TreeNode[parser.ParseNode])
+	if !ok {
+		errorText := fmt.Sprintf("Undefined function %s", funcVariableName)
+		return "", nil, nil, util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
 	}
 
-	funcBodyNode := funcParamNode.Children[1]
-
 	callParamNode := node.Children[2]
-	mathNodes := []*util.TreeNode[parser.ParseNode]{}
+	args := []interface{}{}
 	for callParamNode.Children[0].Value.Name != "funccall" {
-		mathNodes = append(mathNodes, callParamNode.Children[0])
+		value, err := RunMath(callParamNode.Children[0], scope)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		args = append(args, value)
 		callParamNode = callParamNode.Children[1]
 	}
+	return funcVariableName, funcParamNode, args, nil
+}
 
-	if len(mathNodes) != len(funcParamNames) {
-		errorText := fmt.Sprintf(
-			"Unmatching number of function paramaters. %s expected %d, got %d.\n",
-			funcVariableName, len(funcParamNames), len(mathNodes),
-		)
-		util.FatalError(errorText, node.Value.Token.Line, node.Value.Token.Column)
+func RunFuncCall(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
+	funcVariableName, funcParamNode, args, err := functionCall(node, scope)
+	if err != nil {
+		return nil, err
 	}
+	return CallFunction(funcVariableName, funcParamNode, args, scope, node)
+}
 
-	scopeCopy := CopyScope(scope)
-
-	for i := 0; i < len(mathNodes); i++ {
-		value, err := RunMath(mathNodes[i], scope)
+// CallFunction runs a function with already evaluated arguments, node is used for error positions
+func CallFunction(
+	funcVariableName string,
+	funcParamNode *util.TreeNode[parser.ParseNode],
+	args []interface{},
+	scope *Scope,
+	node *util.TreeNode[parser.ParseNode],
+) (interface{}, error) {
+	params, funcBodyNode := functionParams(funcParamNode)
+
+	// Functions that yield do not run when called, they run on their own goroutine as the generator
+	// is consumed, so like tasks they get their own copy of all values
+	if isGenerator(funcBodyNode) {
+		generatorScope := isolatedScope(scope)
+		generatorArgs := make([]interface{}, len(args))
+		for i, arg := range args {
+			generatorArgs[i] = copyValue(arg)
+		}
+		err := bindParams(funcVariableName, params, generatorArgs, generatorScope, node)
 		if err != nil {
 			return nil, err
 		}
-		varName := funcParamNames[i]
-		(*scopeCopy)[varName] = value
+		return newGenerator(funcVariableName, funcBodyNode, generatorScope), nil
+	}
+
+	scopeCopy := CopyScope(scope)
+	err := bindParams(funcVariableName, params, args, scopeCopy, node)
+	if err != nil {
+		return nil, err
 	}
 
 	for funcBodyNode.Children[0].Value.Name != "funcend" {
@@ -557,7 +840,9 @@ func RunPrint(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
 	if err != nil {
 		return err
 	}
-	fmt.Print(result)
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	fmt.Fprint(Stdout, result)
 	return nil
 }
 
@@ -566,40 +851,69 @@ func RunPrintln(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
 	if err != nil {
 		return err
 	}
-	fmt.Println(result)
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	fmt.Fprintln(Stdout, result)
 	return nil
 }
 
 func RunListAppend(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	err := checkAssignable(node.Children[0], scope)
+	if err != nil {
+		return err
+	}
 	if varValue, ok := (*scope)[node.Children[0].Value.Value]; ok {
-		varList := varValue.([]interface{})
+		varList, ok := varValue.([]interface{})
+		if !ok {
+			errorText := fmt.Sprintf("Variable %s is not a list", node.Children[0].Value.Value)
+			return util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
+		}
 		newValue, err := RunValue(node.Children[2], scope)
 		if err != nil {
 			return err
 		}
-		(*scope)[node.Children[0].Value.Value] = append(varList, newValue)
+		// Build a new list so other variables sharing the old one do not see the new value
+		newList := make([]interface{}, 0, len(varList)+1)
+		newList = append(newList, varList...)
+		(*scope)[node.Children[0].Value.Value] = append(newList, newValue)
 	} else {
-		errorText := fmt.Sprintf("Invalid list variable %s", node.Children[0].Value.Name)
+		errorText := fmt.Sprintf("Invalid list variable %s", node.Children[0].Value.Value)
 		return util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
 	}
 	return nil
 }
 
 func RunListPop(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
+	err := checkAssignable(node.Children[0], scope)
+	if err != nil {
+		return nil, err
+	}
 	if varValue, ok := (*scope)[node.Children[0].Value.Value]; ok {
-		varList := varValue.([]interface{})
+		varList, ok := varValue.([]interface{})
+		if !ok {
+			errorText := fmt.Sprintf("Variable %s is not a list", node.Children[0].Value.Value)
+			return nil, util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
+		}
 		val, err := RunValue(node.Children[2], scope)
 		if err != nil {
 			return nil, err
 		}
 		indexValue, ok := val.(int64)
 		if !ok {
-			util.FatalError("Invalid list index value type", node.Value.Token.Line, node.Value.Token.Column)
+			return nil, util.FormatError("Invalid list index value type", node.Value.Token.Line, node.Value.Token.Column)
+		}
+		listLen := int64(len(varList))
+		if indexValue < 0 || indexValue >= listLen {
+			errorText := fmt.Sprintf("List index %d out of range [%d, %d]", indexValue, 0, listLen-1)
+			return nil, util.FormatError(errorText, node.Value.Token.Line, node.Value.Token.Column)
 		}
 		returnValue := varList[indexValue]
 
-		varList = append(varList[:indexValue], varList[indexValue+1:]...)
-		(*scope)[node.Children[0].Value.Value] = varList
+		// Build a new list so other variables sharing the old one are not shifted
+		newList := make([]interface{}, 0, listLen-1)
+		newList = append(newList, varList[:indexValue]...)
+		newList = append(newList, varList[indexValue+1:]...)
+		(*scope)[node.Children[0].Value.Value] = newList
 
 		return returnValue, nil
 	}
@@ -608,6 +922,11 @@ func RunListPop(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{
 }
 
 func RunExpression(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	// An exit in another task stops this one too
+	if err := stopError(); err != nil {
+		return err
+	}
+
 	for _, childNode := range node.Children {
 		switch childNode.Value.Name {
 		case "ASSIGN":
@@ -618,10 +937,16 @@ func RunExpression(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
 			return RunPrintln(childNode, scope)
 		case "IF":
 			return RunIf(childNode, scope)
+		case "MATCH":
+			return RunMatch(childNode, scope)
 		case "LOOP":
 			return RunLoop(childNode, scope)
+		case "FOREACH":
+			return RunForEach(childNode, scope)
 		case "FUNC":
 			return RunFunc(childNode, scope)
+		case "RECORD":
+			return RunRecord(childNode, scope)
 		case "FUNCRETURN":
 			return RunReturn(childNode, scope)
 		case "FUNCCALL":
@@ -632,15 +957,34 @@ func RunExpression(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
 		case "LISTPOP":
 			_, err := RunListPop(childNode, scope)
 			return err
+		case "CONST":
+			return RunConst(childNode, scope)
+		case "FILEWRITE":
+			return RunFileWrite(childNode, scope)
+		case "EXIT":
+			return RunExit(childNode, scope)
+		case "ASSERT":
+			return RunAssert(childNode, scope)
+		case "TRY":
+			return RunTry(childNode, scope)
+		case "THROW":
+			return RunThrow(childNode, scope)
+		case "YIELD":
+			return RunYield(childNode, scope)
+		case "SPAWN":
+			return RunSpawn(childNode, scope)
+		case "CHANNELOP":
+			return RunChannelOp(childNode, scope)
 		}
 	}
 
 	return nil
 }
 
-func RunProgram(node *util.TreeNode[parser.ParseNode], scope *Scope) {
+// RunProgram runs the program until it ends, returns at file scope or fails with an uncaught error
+func RunProgram(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
 	if node.Value.Name != "PROGRAM" {
-		log.Fatalf("Failed to run program, unexpected node %s\n", node.Value.Name)
+		return fmt.Errorf("failed to run program, unexpected node %s", node.Value.Name)
 	}
 
 	currentProgram := node
@@ -659,8 +1003,9 @@ func RunProgram(node *util.TreeNode[parser.ParseNode], scope *Scope) {
 		if expressionNode != nil {
 			err := RunExpression(expressionNode, scope)
 			if err != nil {
-				util.FatalError(fmt.Sprint(err), expressionNode.Value.Token.Line, expressionNode.Value.Token.Column)
+				return PositionError(err, expressionNode)
 			}
+			// Returning at file scope ends the program
 			if ScopeIsReturning(scope) {
 				break
 			}
@@ -668,9 +1013,14 @@ func RunProgram(node *util.TreeNode[parser.ParseNode], scope *Scope) {
 
 		currentProgram = nextProgram
 	}
+	return nil
 }
 
-func RunTree(parseTree *util.TreeNode[parser.ParseNode]) {
+// RunTree runs a parsed program and returns its exit code
+func RunTree(parseTree *util.TreeNode[parser.ParseNode]) int {
 	newScope := Scope{}
-	RunProgram(parseTree, &newScope)
+	startTime = time.Now()
+	startProgram()
+	err := RunProgram(parseTree, &newScope)
+	return ExitCode(finishProgram(err))
 }
@@ -0,0 +1,256 @@
+package runtime
+
+import (
+	"JureBevc/peepoo/parser"
+	"JureBevc/peepoo/util"
+	"cmp"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Evaluates the arguments of a built-in, which follow the built-in keyword
+func builtinArgs(node *util.TreeNode[parser.ParseNode], scope *Scope) ([]interface{}, error) {
+	args := []interface{}{}
+	for _, argNode := range node.Children[1:] {
+		value, err := RunValue(argNode, scope)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+	return args, nil
+}
+
+func listArg(value interface{}, node *util.TreeNode[parser.ParseNode]) ([]interface{}, error) {
+	if s, ok := value.(String); ok {
+		return StringToList(string(s)), nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, util.FormatError("Argument is not a list", node.Value.Token.Line, node.Value.Token.Column)
+	}
+	return list, nil
+}
+
+func functionArg(value interface{}, node *util.TreeNode[parser.ParseNode]) (*util.TreeNode[parser.ParseNode], error) {
+	function, ok := value.(*util.TreeNode[parser.ParseNode])
+	if !ok {
+		return nil, util.FormatError("Argument is not a function", node.Value.Token.Line, node.Value.Token.Column)
+	}
+	return function, nil
+}
+
+// Name of a function argument for error messages
+func functionArgName(node *util.TreeNode[parser.ParseNode], index int) string {
+	argNode := node.Children[index]
+	if argNode.Children[0].Value.Name == "var" {
+		return argNode.Children[0].Value.Value
+	}
+	return "function"
+}
+
+// ValuesEqual compares two values, lists are equal when all their elements are equal
+func ValuesEqual(a interface{}, b interface{}) bool {
+	switch aValue := a.(type) {
+	case []interface{}:
+		bValue, ok := b.([]interface{})
+		if !ok || len(aValue) != len(bValue) {
+			return false
+		}
+		for i := range aValue {
+			if !ValuesEqual(aValue[i], bValue[i]) {
+				return false
+			}
+		}
+		return true
+	case *Record:
+		bValue, ok := b.(*Record)
+		if !ok || aValue.Name != bValue.Name {
+			return false
+		}
+		return ValuesEqual(aValue.Values, bValue.Values)
+	case int64, string, String, *util.TreeNode[parser.ParseNode], *Generator, *Channel:
+		return a == b
+	}
+	return false
+}
+
+// CompareValues orders two ints, two chars or strings, or two lists element by element
+func CompareValues(a interface{}, b interface{}) (int, error) {
+	switch aValue := a.(type) {
+	case int64:
+		if bValue, ok := b.(int64); ok {
+			return cmp.Compare(aValue, bValue), nil
+		}
+	case string:
+		if bValue, ok := b.(string); ok {
+			return strings.Compare(aValue, bValue), nil
+		}
+	case String:
+		if bValue, ok := b.(String); ok {
+			return strings.Compare(string(aValue), string(bValue)), nil
+		}
+	case []interface{}:
+		if bValue, ok := b.([]interface{}); ok {
+			for i := 0; i < len(aValue) && i < len(bValue); i++ {
+				result, err := CompareValues(aValue[i], bValue[i])
+				if err != nil || result != 0 {
+					return result, err
+				}
+			}
+			return cmp.Compare(len(aValue), len(bValue)), nil
+		}
+	}
+	return 0, fmt.Errorf("Cannot compare %s and %s", typeNames[TypeOf(a)], typeNames[TypeOf(b)])
+}
+
+func truthy(value interface{}) bool {
+	number, ok := value.(int64)
+	return ok && number != 0
+}
+
+func sortList(list []interface{}, compare func(a interface{}, b interface{}) (int, error)) ([]interface{}, error) {
+	newList := make([]interface{}, len(list))
+	copy(newList, list)
+
+	var sortErr error
+	sort.SliceStable(newList, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+		result, err := compare(newList[i], newList[j])
+		if err != nil {
+			sortErr = err
+		}
+		return result < 0
+	})
+	return newList, sortErr
+}
+
+func RunListLibrary(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
+	builtinNode := node.Children[0]
+	line := builtinNode.Value.Token.Line
+	column := builtinNode.Value.Token.Column
+
+	args, err := builtinArgs(node, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// Strings can be used wherever a list is expected
+	list, err := listArg(args[0], builtinNode)
+	if err != nil {
+		return nil, err
+	}
+
+	switch builtinNode.Value.Name {
+	case "sort":
+		newList, err := sortList(list, CompareValues)
+		if err != nil {
+			return nil, util.FormatError(err.Error(), line, column)
+		}
+		return newList, nil
+	case "sortby":
+		function, err := functionArg(args[1], builtinNode)
+		if err != nil {
+			return nil, err
+		}
+		name := functionArgName(node, 2)
+		return sortList(list, func(a interface{}, b interface{}) (int, error) {
+			result, err := CallFunction(name, function, []interface{}{a, b}, scope, builtinNode)
+			if err != nil {
+				return 0, err
+			}
+			order, ok := result.(int64)
+			if !ok {
+				return 0, util.FormatError("Sort function must return a number", line, column)
+			}
+			return cmp.Compare(order, 0), nil
+		})
+	case "reverse":
+		newList := make([]interface{}, len(list))
+		for i, value := range list {
+			newList[len(list)-1-i] = value
+		}
+		return newList, nil
+	case "contains", "indexof":
+		index := int64(-1)
+		for i, value := range list {
+			if ValuesEqual(value, args[1]) {
+				index = int64(i)
+				break
+			}
+		}
+		if builtinNode.Value.Name == "contains" {
+			if index >= 0 {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		}
+		return index, nil
+	case "join":
+		separator, ok := ListToString(args[1])
+		if !ok {
+			return nil, util.FormatError("Join separator is not a list of chars", line, column)
+		}
+		parts := []string{}
+		for _, value := range list {
+			part, err := ToString(value, builtinNode)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, string(part.(String)))
+		}
+		return StringToList(strings.Join(parts, separator)), nil
+	case "split":
+		text, ok := ListToString(args[0])
+		if !ok {
+			return nil, util.FormatError("Split text is not a list of chars", line, column)
+		}
+		separator, ok := ListToString(args[1])
+		if !ok {
+			return nil, util.FormatError("Split separator is not a list of chars", line, column)
+		}
+		parts := []interface{}{}
+		for _, part := range strings.Split(text, separator) {
+			parts = append(parts, StringToList(part))
+		}
+		return parts, nil
+	case "map", "filter":
+		function, err := functionArg(args[1], builtinNode)
+		if err != nil {
+			return nil, err
+		}
+		name := functionArgName(node, 2)
+		newList := []interface{}{}
+		for _, value := range list {
+			result, err := CallFunction(name, function, []interface{}{value}, scope, builtinNode)
+			if err != nil {
+				return nil, err
+			}
+			if builtinNode.Value.Name == "map" {
+				newList = append(newList, result)
+			} else if truthy(result) {
+				newList = append(newList, value)
+			}
+		}
+		return newList, nil
+	case "reduce":
+		function, err := functionArg(args[1], builtinNode)
+		if err != nil {
+			return nil, err
+		}
+		name := functionArgName(node, 2)
+		accumulator := args[2]
+		for _, value := range list {
+			accumulator, err = CallFunction(name, function, []interface{}{accumulator, value}, scope, builtinNode)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return accumulator, nil
+	}
+
+	return nil, util.FormatError("Unknown list built-in", line, column)
+}
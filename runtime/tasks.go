@@ -0,0 +1,344 @@
+package runtime
+
+import (
+	"JureBevc/peepoo/parser"
+	"JureBevc/peepoo/util"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Spawned tasks, the program waits for all of them before it ends
+var tasks sync.WaitGroup
+
+// First uncaught error of a spawned task
+var taskErr error
+var taskErrMutex sync.Mutex
+
+// Set by an exit in any task, every task stops at its next expression
+var stopping atomic.Pointer[ExitError]
+
+// All channels share one lock, so that the runtime can see when every thread is waiting.
+// A thread is the main program or a task, a generator runs as part of the thread consuming it.
+var channelMutex sync.Mutex
+var channelCond = sync.NewCond(&channelMutex)
+var runningThreads int
+var channelWaiters = map[*channelWaiter]bool{}
+var deadlockCount int
+
+var errChannelClosed = errors.New("Cannot send to a closed channel")
+var errDeadlock = errors.New("Deadlock, every task is waiting on a channel")
+
+type channelWaiter struct {
+	ready func() bool
+}
+
+// Channel passes values between tasks. Every send gets a ticket, a sender waits until
+// its value is received or fits into the channel capacity.
+type Channel struct {
+	capacity int
+	values   []interface{}
+	sent     int
+	received int
+	closed   bool
+}
+
+func (c *Channel) String() string {
+	return "channel"
+}
+
+// Waits until ready returns true, channelMutex must be held
+func waitForChannel(ready func() bool) error {
+	waiter := &channelWaiter{ready: ready}
+	channelWaiters[waiter] = true
+	defer delete(channelWaiters, waiter)
+
+	deadlocks := deadlockCount
+	for !ready() {
+		if stop := stopping.Load(); stop != nil {
+			return stop
+		}
+		if deadlocks != deadlockCount {
+			return errDeadlock
+		}
+		if len(channelWaiters) >= runningThreads && !anyWaiterReady() {
+			// Nobody is left to send or receive, wake up every waiting thread with an error
+			deadlockCount++
+			channelCond.Broadcast()
+			return errDeadlock
+		}
+		channelCond.Wait()
+	}
+	return nil
+}
+
+func anyWaiterReady() bool {
+	for waiter := range channelWaiters {
+		if waiter.ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// Send waits until the value is received or buffered
+func (c *Channel) Send(value interface{}) error {
+	channelMutex.Lock()
+	defer channelMutex.Unlock()
+	if c.closed {
+		return errChannelClosed
+	}
+
+	ticket := c.sent
+	c.sent++
+	c.values = append(c.values, value)
+	channelCond.Broadcast()
+
+	err := waitForChannel(func() bool {
+		return c.closed || ticket < c.received+c.capacity
+	})
+	if err == nil && ticket >= c.sent {
+		// The channel was closed before the value was received
+		return errChannelClosed
+	}
+	return err
+}
+
+// Receive waits until a value is sent, returns false once the channel is closed and empty
+func (c *Channel) Receive() (interface{}, bool, error) {
+	channelMutex.Lock()
+	defer channelMutex.Unlock()
+
+	err := waitForChannel(func() bool {
+		return len(c.values) > 0 || c.closed
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(c.values) == 0 {
+		return nil, false, nil
+	}
+
+	value := c.values[0]
+	c.values = c.values[1:]
+	c.received++
+	channelCond.Broadcast()
+	return value, true, nil
+}
+
+// Close returns false if the channel was already closed. Values that fit into the channel
+// can still be received, senders still waiting for a receiver fail.
+func (c *Channel) Close() bool {
+	channelMutex.Lock()
+	defer channelMutex.Unlock()
+	if c.closed {
+		return false
+	}
+
+	c.closed = true
+	kept := c.received + c.capacity
+	if c.sent > kept {
+		c.values = c.values[:kept-c.received]
+		c.sent = kept
+	}
+	channelCond.Broadcast()
+	return true
+}
+
+// Copies lists and records, so that values given to another task are never shared with it
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		newList := make([]interface{}, len(v))
+		for i, element := range v {
+			newList[i] = copyValue(element)
+		}
+		return newList
+	case *Record:
+		return &Record{Name: v.Name, Fields: v.Fields, Values: copyValue(v.Values).([]interface{})}
+	}
+	return value
+}
+
+func isolatedScope(scope *Scope) *Scope {
+	newScope := Scope{}
+	for key, val := range *scope {
+		newScope[key] = copyValue(val)
+	}
+	delete(newScope, "RET")
+	return &newScope
+}
+
+func recordTaskError(err error) {
+	taskErrMutex.Lock()
+	defer taskErrMutex.Unlock()
+	if taskErr == nil {
+		taskErr = err
+	}
+}
+
+// Returns the exit error if a task exited the program
+func stopError() error {
+	if stop := stopping.Load(); stop != nil {
+		return stop
+	}
+	return nil
+}
+
+func requestStop(exitErr *ExitError) {
+	stopping.CompareAndSwap(nil, exitErr)
+	channelMutex.Lock()
+	channelCond.Broadcast()
+	channelMutex.Unlock()
+}
+
+func startThread() {
+	channelMutex.Lock()
+	runningThreads++
+	channelMutex.Unlock()
+}
+
+func finishThread() {
+	channelMutex.Lock()
+	runningThreads--
+	channelCond.Broadcast()
+	channelMutex.Unlock()
+}
+
+// Starts the main thread of a program
+func startProgram() {
+	stopping.Store(nil)
+	startThread()
+}
+
+// Ends the main thread of a program, waits for its tasks and returns the error the program ends with.
+// An exit in any thread ends the program right away.
+func finishProgram(err error) error {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		requestStop(exitErr)
+		finishThread()
+		closeGenerators()
+		return err
+	}
+
+	finishThread()
+	tasks.Wait()
+	closeGenerators()
+	taskErrMutex.Lock()
+	firstTaskErr := taskErr
+	taskErr = nil
+	taskErrMutex.Unlock()
+
+	// A task can exit after the main thread has finished
+	if stop := stopping.Load(); stop != nil {
+		return stop
+	}
+	if err == nil {
+		err = firstTaskErr
+	}
+	return err
+}
+
+func RunSpawn(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	callNode := node.Children[1]
+	funcVariableName, funcParamNode, args, err := functionCall(callNode, scope)
+	if err != nil {
+		return err
+	}
+
+	// Tasks run with their own copy of the scope and arguments
+	taskScope := isolatedScope(scope)
+	delete(*taskScope, generatorKey)
+	for i := range args {
+		args[i] = copyValue(args[i])
+	}
+
+	tasks.Add(1)
+	startThread()
+	go func() {
+		defer tasks.Done()
+		defer finishThread()
+		_, err := CallFunction(funcVariableName, funcParamNode, args, taskScope, callNode)
+
+		var exitErr *ExitError
+		if errors.As(err, &exitErr) {
+			requestStop(exitErr)
+		} else if err != nil {
+			recordTaskError(PositionError(err, callNode))
+		}
+	}()
+	return nil
+}
+
+func channelArg(value interface{}, node *util.TreeNode[parser.ParseNode]) (*Channel, error) {
+	channel, ok := value.(*Channel)
+	if !ok {
+		return nil, util.FormatError("Value is not a channel", node.Value.Token.Line, node.Value.Token.Column)
+	}
+	return channel, nil
+}
+
+// Runs the channel built-ins that return a value
+func RunChannel(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
+	builtinNode := node.Children[0]
+	value, err := RunValue(node.Children[1], scope)
+	if err != nil {
+		return nil, err
+	}
+
+	switch builtinNode.Value.Name {
+	case "channel":
+		capacity, ok := value.(int64)
+		if !ok || capacity < 0 {
+			errorText := fmt.Sprintf("Invalid channel capacity %v", value)
+			return nil, util.FormatError(errorText, builtinNode.Value.Token.Line, builtinNode.Value.Token.Column)
+		}
+		return &Channel{capacity: int(capacity)}, nil
+	case "receive":
+		channel, err := channelArg(value, builtinNode)
+		if err != nil {
+			return nil, err
+		}
+		received, ok, err := channel.Receive()
+		if err != nil {
+			return nil, PositionError(err, builtinNode)
+		}
+		if !ok {
+			return nil, util.FormatError("Channel is closed", builtinNode.Value.Token.Line, builtinNode.Value.Token.Column)
+		}
+		return received, nil
+	}
+	return nil, util.FormatError("Failed to parse value", builtinNode.Value.Token.Line, builtinNode.Value.Token.Column)
+}
+
+// Runs the channel built-ins used as statements
+func RunChannelOp(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	builtinNode := node.Children[0]
+	value, err := RunValue(node.Children[1], scope)
+	if err != nil {
+		return err
+	}
+	channel, err := channelArg(value, builtinNode)
+	if err != nil {
+		return err
+	}
+
+	switch builtinNode.Value.Name {
+	case "send":
+		sent, err := RunValue(node.Children[2], scope)
+		if err != nil {
+			return err
+		}
+		err = channel.Send(copyValue(sent))
+		if err != nil {
+			return PositionError(err, builtinNode)
+		}
+	case "close":
+		if !channel.Close() {
+			return util.FormatError("Channel is already closed", builtinNode.Value.Token.Line, builtinNode.Value.Token.Column)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package runtime
+
+import (
+	"JureBevc/peepoo/parser"
+	"JureBevc/peepoo/util"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func RunAssert(node *util.TreeNode[parser.ParseNode], scope *Scope) error {
+	builtinNode := node.Children[0]
+	line := builtinNode.Value.Token.Line
+	column := builtinNode.Value.Token.Column
+
+	if builtinNode.Value.Name == "assert" {
+		value, err := RunMath(node.Children[1], scope)
+		if err != nil {
+			return err
+		}
+		if !truthy(value) {
+			return util.FormatError("Assertion failed", line, column)
+		}
+		return nil
+	}
+
+	args, err := builtinArgs(node, scope)
+	if err != nil {
+		return err
+	}
+	if !ValuesEqual(args[0], args[1]) {
+		errorText := fmt.Sprintf("Assertion failed, got %v, expected %v", args[0], args[1])
+		return util.FormatError(errorText, line, column)
+	}
+	return nil
+}
+
+// Test functions have names starting with the prefix of the assertion built-ins
+const testPrefix = "POPO"
+
+// TestFunctions returns the names of the tests in a program, which are the functions
+// without parameters defined at file scope whose names start with testPrefix
+func TestFunctions(node *util.TreeNode[parser.ParseNode]) []string {
+	names := []string{}
+	for currentProgram := node; currentProgram != nil; {
+		var nextProgram *util.TreeNode[parser.ParseNode] = nil
+		for _, childNode := range currentProgram.Children {
+			if childNode.Value.Name == "PROGRAM" {
+				nextProgram = childNode
+				continue
+			}
+
+			funcNode := childNode.Children[0]
+			if funcNode.Value.Name != "FUNC" || funcNode.Children[2].Children[0].Value.Name != "funcstart" {
+				continue
+			}
+			if name := funcNode.Children[1].Value.Value; strings.HasPrefix(name, testPrefix) {
+				names = append(names, name)
+			}
+		}
+		currentProgram = nextProgram
+	}
+	return names
+}
+
+// RunTest runs the file scope code of a program in a new scope and then calls the test function,
+// so every test starts from the same state
+func RunTest(node *util.TreeNode[parser.ParseNode], name string) error {
+	scope := Scope{}
+	startProgram()
+	err := RunProgram(node, &scope)
+
+	// An exit in the file scope code means the test never ran, which is not a pass
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		finishProgram(err)
+		return fmt.Errorf("test %s did not run, the program exited with %d before it", name, exitErr.Code)
+	}
+
+	if err == nil {
+		funcParamNode, ok := scope[name].(*util.TreeNode[parser.ParseNode])
+		if ok {
+			_, err = CallFunction(name, funcParamNode, nil, &scope, node)
+		} else {
+			err = fmt.Errorf("test %s is not a function", name)
+		}
+	}
+	err = finishProgram(err)
+
+	if errors.As(err, &exitErr) && exitErr.Code == 0 {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package runtime
+
+import (
+	"JureBevc/peepoo/parser"
+	"JureBevc/peepoo/util"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var startTime = time.Now()
+
+var random = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// Random sources are not safe for concurrent use
+var randomMutex sync.Mutex
+
+// SetSeed makes the random built-in return the same numbers on every run with the same seed
+func SetSeed(seed int64) {
+	randomMutex.Lock()
+	defer randomMutex.Unlock()
+	random = rand.New(rand.NewSource(seed))
+}
+
+// Milliseconds since the program started, never goes backwards
+func clockMillis() int64 {
+	return time.Since(startTime).Milliseconds()
+}
+
+// Milliseconds since the Unix epoch
+func wallTimeMillis() int64 {
+	return time.Now().UnixMilli()
+}
+
+func RunRandom(node *util.TreeNode[parser.ParseNode], scope *Scope) (interface{}, error) {
+	builtinNode := node.Children[0]
+	value, err := RunValue(node.Children[1], scope)
+	if err != nil {
+		return nil, err
+	}
+	limit, ok := value.(int64)
+	if !ok || limit <= 0 {
+		return nil, util.FormatError(
+			"Random number limit must be a positive number",
+			builtinNode.Value.Token.Line,
+			builtinNode.Value.Token.Column,
+		)
+	}
+	randomMutex.Lock()
+	defer randomMutex.Unlock()
+	return random.Int63n(limit), nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"JureBevc/peepoo/runtime"
+	"JureBevc/peepoo/util"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// Runs every test in the *_test.peepoo files found in paths and returns the exit code
+func runTests(paths []string) int {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	testFiles := []string{}
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Like go test, testdata directories hold files for tests and are only run when given directly
+			if entry.IsDir() && entry.Name() == "testdata" && filePath != path {
+				return filepath.SkipDir
+			}
+			if !entry.IsDir() && strings.HasSuffix(filePath, "_test.peepoo") {
+				testFiles = append(testFiles, filePath)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("Failed to find tests in %s: %s\n", path, err)
+			return util.ExitTestFailure
+		}
+	}
+
+	passed := 0
+	failed := 0
+	for _, testFile := range testFiles {
+		ptree, err := parseProgram(testFile)
+		if err != nil {
+			fmt.Printf("FAIL %s\n    %s\n", testFile, err)
+			failed++
+			continue
+		}
+
+		for _, name := range runtime.TestFunctions(ptree) {
+			err := runtime.RunTest(ptree, name)
+			if err != nil {
+				fmt.Printf("FAIL %s %s\n    %s\n", testFile, name, err)
+				failed++
+			} else {
+				fmt.Printf("PASS %s %s\n", testFile, name)
+				passed++
+			}
+		}
+	}
+
+	fmt.Printf("%d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		return util.ExitTestFailure
+	}
+	return 0
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"JureBevc/peepoo/util"
+	"testing"
+)
+
+func TestRunTests(t *testing.T) {
+	cases := []struct {
+		file string
+		code int
+	}{
+		{"testdata/tests/pass_test.peepoo", 0},
+		{"testdata/tests/fail_test.peepoo", util.ExitTestFailure},
+		{"testdata/tests/exit_test.peepoo", util.ExitTestFailure},
+		{"testdata/tests/error_test.peepoo", util.ExitTestFailure},
+	}
+
+	for _, c := range cases {
+		t.Run(c.file, func(t *testing.T) {
+			if code := runTests([]string{c.file}); code != c.code {
+				t.Errorf("exit code %d, expected %d", code, c.code)
+			}
+		})
+	}
+}
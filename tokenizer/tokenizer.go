@@ -25,6 +25,8 @@ type Token struct {
 	Value  string
 	Line   int
 	Column int
+	// Doc comment written before a function, only set on funcstart tokens
+	Doc string
 }
 
 func loadTokenFile(pathToTokenFile embed.FS) *[]TokenDefinition {
@@ -94,13 +96,12 @@ func wordSingleDefinition(tokenDefinitions *[]TokenDefinition, word string) (Tok
 	return validDefinition, nil
 }
 
-func parseFile(tokenDefinitons *[]TokenDefinition, pathToInputFile string) *[]Token {
+func parseFile(tokenDefinitons *[]TokenDefinition, pathToInputFile string) (*[]Token, error) {
 	var tokens []Token
 
 	file, err := os.Open(pathToInputFile)
 	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return nil
+		return nil, fmt.Errorf("error opening file: %w", err)
 	}
 	defer file.Close()
 
@@ -109,8 +110,28 @@ func parseFile(tokenDefinitons *[]TokenDefinition, pathToInputFile string) *[]To
 	line := 1
 	column := 1
 
+	// Reads the next char if it matches, comment markers are two chars long
+	skipNext := func(next byte) bool {
+		peeked, err := reader.Peek(1)
+		if err == nil && peeked[0] == next {
+			reader.ReadByte()
+			column++
+			return true
+		}
+		return false
+	}
+
 	currentWord := ""
 	inComment := false
+	// Block comments #[ ... ]# can span lines and nest
+	blockDepth := 0
+	blockLine, blockColumn := 0, 0
+	// Doc comments are lines starting with ##, they are kept for the function that follows them
+	inDocComment := false
+	docLine := ""
+	docLines := []string{}
+	// Only whitespace was read since the start of the line
+	atLineStart := true
 	for {
 		char, _, err := reader.ReadRune()
 
@@ -120,14 +141,42 @@ func parseFile(tokenDefinitons *[]TokenDefinition, pathToInputFile string) *[]To
 			reachedEnd = true
 		}
 
-		if char == '#' {
-			inComment = true
+		inBlockComment := blockDepth > 0
+		if blockDepth > 0 {
+			if char == '#' && skipNext('[') {
+				blockDepth++
+			} else if char == ']' && skipNext('#') {
+				blockDepth--
+			}
+		} else if char == '#' && !inComment {
+			commentColumn := column
+			if skipNext('[') {
+				blockDepth = 1
+				blockLine, blockColumn = line, commentColumn
+				inBlockComment = true
+			} else {
+				inComment = true
+				if skipNext('#') && atLineStart {
+					inDocComment = true
+				}
+			}
+		} else if inDocComment && char != '\n' && !reachedEnd {
+			docLine += string(char)
 		}
-		if char == '\n' {
+		if !unicode.IsSpace(char) {
+			atLineStart = false
+		}
+		if char == '\n' || reachedEnd {
+			atLineStart = true
+			if inDocComment {
+				docLines = append(docLines, strings.TrimSpace(docLine))
+				docLine = ""
+			}
 			inComment = false
+			inDocComment = false
 		}
 		// Create next word if current char is not empty
-		emptyChar := unicode.IsSpace(char) || inComment
+		emptyChar := unicode.IsSpace(char) || inComment || inBlockComment
 		nextWord := currentWord
 		if !reachedEnd && !emptyChar {
 			nextWord = currentWord + string(char)
@@ -154,13 +203,20 @@ func parseFile(tokenDefinitons *[]TokenDefinition, pathToInputFile string) *[]To
 
 		if parseCurrentWord {
 			if currentDefError != nil {
-				util.FatalError(fmt.Sprintf("Unknown token %s", currentWord), line, column)
+				return nil, util.FormatError(fmt.Sprintf("Unknown token %s", currentWord), line, column)
+			}
+			// Doc comments that are not followed by a function are ordinary comments
+			doc := ""
+			if currentDefinition.Name == "funcstart" {
+				doc = strings.Join(docLines, "\n")
 			}
+			docLines = []string{}
 			tokens = append(tokens, Token{
 				Name:   currentDefinition.Name,
 				Value:  currentWord,
 				Line:   line,
 				Column: column,
+				Doc:    doc,
 			})
 		}
 
@@ -185,11 +241,15 @@ func parseFile(tokenDefinitons *[]TokenDefinition, pathToInputFile string) *[]To
 		}
 	}
 
-	return &tokens
+	if blockDepth > 0 {
+		return nil, util.FormatError("Block comment is never closed", blockLine, blockColumn)
+	}
+
+	return &tokens, nil
 }
 
-func Tokenize(pathToTokenFile embed.FS, pathToInputFile string) (*[]TokenDefinition, *[]Token) {
+func Tokenize(pathToTokenFile embed.FS, pathToInputFile string) (*[]TokenDefinition, *[]Token, error) {
 	tokenDef := loadTokenFile(pathToTokenFile)
-	tokens := parseFile(tokenDef, pathToInputFile)
-	return tokenDef, tokens
+	tokens, err := parseFile(tokenDef, pathToInputFile)
+	return tokenDef, tokens, err
 }
@@ -5,11 +5,29 @@ import (
 	"os"
 )
 
+// Error is an error tied to a position in the program source
+type Error struct {
+	Text   string
+	Line   int
+	Column int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ln %d col %d: %s", e.Line, e.Column, e.Text)
+}
+
+// Exit codes of the interpreter, programs can also exit with their own code
+const (
+	ExitSyntaxError  = 1
+	ExitRuntimeError = 2
+	ExitTestFailure  = 3
+)
+
 func FatalError(text string, line int, column int) {
 	fmt.Println(FormatError(text, line, column))
-	os.Exit(1)
+	os.Exit(ExitSyntaxError)
 }
 
 func FormatError(text string, line int, column int) error {
-	return fmt.Errorf("ln %d col %d: %s", line, column, text)
+	return &Error{Text: text, Line: line, Column: column}
 }